@@ -0,0 +1,160 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+type AvroAsyncProducerConfig struct {
+	AvroProducerConfig
+	// Linger is the maximum time messages are buffered before a batch is sent
+	Linger time.Duration
+	// BatchSize is the number of buffered messages that triggers sending a batch
+	BatchSize int
+	// BatchBytes is the number of buffered bytes that triggers sending a batch
+	BatchBytes int
+	// OnDelivery is called for every message once it is acknowledged or failed,
+	// when nil the reports are sent to Deliveries() instead
+	OnDelivery func(report DeliveryReport)
+}
+
+// DeliveryReport holds the outcome of an asynchronously produced message
+type DeliveryReport struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	// Metadata is the value passed to Add, it is not sent to kafka
+	Metadata interface{}
+	Err      error
+}
+
+// AvroAsyncProducer batches messages in the background and reports their delivery asynchronously
+type AvroAsyncProducer struct {
+	producer             sarama.AsyncProducer
+	schemaRegistryClient SchemaRegistryClientInterface
+	onDelivery           func(report DeliveryReport)
+	deliveries           chan DeliveryReport
+	wg                   sync.WaitGroup
+	// lock guards closed, Add holds it for reading while it sends to the input of the producer
+	lock    sync.RWMutex
+	closed  bool
+	closing chan struct{}
+}
+
+// NewAvroAsyncProducer is a producer built on sarama.AsyncProducer, messages are sent in batches
+// and Add returns as soon as the message is encoded
+func NewAvroAsyncProducer(cfg AvroAsyncProducerConfig) (*AvroAsyncProducer, error) {
+	config := newProducerConfig(cfg.AvroProducerConfig)
+	config.Producer.Return.Errors = true
+	config.Producer.Flush.Frequency = cfg.Linger
+	config.Producer.Flush.Messages = cfg.BatchSize
+	config.Producer.Flush.Bytes = cfg.BatchBytes
+	producer, err := sarama.NewAsyncProducer(cfg.KafkaServers, config)
+	if err != nil {
+		return nil, err
+	}
+	return newAvroAsyncProducer(producer, cfg.schemaRegistryClient(), cfg.OnDelivery), nil
+}
+
+func newAvroAsyncProducer(producer sarama.AsyncProducer, schemaRegistryClient SchemaRegistryClientInterface, onDelivery func(report DeliveryReport)) *AvroAsyncProducer {
+	ap := &AvroAsyncProducer{
+		producer:             producer,
+		schemaRegistryClient: schemaRegistryClient,
+		onDelivery:           onDelivery,
+		deliveries:           make(chan DeliveryReport, 256),
+		closing:              make(chan struct{}),
+	}
+	ap.wg.Add(2)
+	go func() {
+		defer ap.wg.Done()
+		for msg := range producer.Successes() {
+			ap.report(DeliveryReport{msg.Topic, msg.Partition, msg.Offset, msg.Metadata, nil})
+		}
+	}()
+	go func() {
+		defer ap.wg.Done()
+		for err := range producer.Errors() {
+			ap.report(DeliveryReport{err.Msg.Topic, err.Msg.Partition, err.Msg.Offset, err.Msg.Metadata, err.Err})
+		}
+	}()
+	return ap
+}
+
+func (ap *AvroAsyncProducer) report(report DeliveryReport) {
+	if ap.onDelivery != nil {
+		ap.onDelivery(report)
+		return
+	}
+	select {
+	case ap.deliveries <- report:
+		return
+	default:
+	}
+	select {
+	case ap.deliveries <- report:
+	case <-ap.closing:
+		// nobody reads the full channel and Close is waiting for the reports, drop it
+	}
+}
+
+// Deliveries returns the delivery reports when no OnDelivery callback is configured,
+// it must be read or the producer will block. The channel is closed by Close.
+func (ap *AvroAsyncProducer) Deliveries() <-chan DeliveryReport {
+	return ap.deliveries
+}
+
+// Add queues an avro encoded value without a key, metadata is returned in its DeliveryReport
+func (ap *AvroAsyncProducer) Add(topic string, schema string, value []byte, metadata interface{}) error {
+	return ap.AddContext(context.Background(), topic, schema, value, metadata)
+}
+
+// AddContext is Add with a context bounding the schema registry requests and the wait for the input queue
+func (ap *AvroAsyncProducer) AddContext(ctx context.Context, topic string, schema string, value []byte, metadata interface{}) error {
+	return ap.AddWithKeyContext(ctx, topic, MessageKey{}, schema, value, metadata)
+}
+
+// AddWithKey queues an avro encoded value with the given key, metadata is returned in its DeliveryReport.
+// Only encoding and schema registry errors are returned, delivery errors are reported asynchronously.
+func (ap *AvroAsyncProducer) AddWithKey(topic string, key MessageKey, schema string, value []byte, metadata interface{}) error {
+	return ap.AddWithKeyContext(context.Background(), topic, key, schema, value, metadata)
+}
+
+// AddWithKeyContext is AddWithKey with a context bounding the schema registry requests and the wait for the input queue
+func (ap *AvroAsyncProducer) AddWithKeyContext(ctx context.Context, topic string, key MessageKey, schema string, value []byte, metadata interface{}) error {
+	msg, err := newAvroMessage(ctx, ap.schemaRegistryClient, topic, key, schema, value)
+	if err != nil {
+		return err
+	}
+	msg.Metadata = metadata
+	ap.lock.RLock()
+	defer ap.lock.RUnlock()
+	if ap.closed {
+		return ErrProducerClosed
+	}
+	select {
+	case ap.producer.Input() <- msg:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close flushes the buffered messages, waits for their delivery reports and closes Deliveries().
+// Reports that do not fit the buffer of Deliveries() while closing are dropped instead of blocking Close,
+// read Deliveries() until it is closed to receive all of them. Add returns ErrProducerClosed afterwards.
+func (ap *AvroAsyncProducer) Close() {
+	ap.lock.Lock()
+	if ap.closed {
+		ap.lock.Unlock()
+		return
+	}
+	ap.closed = true
+	ap.lock.Unlock()
+	close(ap.closing)
+	ap.producer.AsyncClose()
+	ap.wg.Wait()
+	close(ap.deliveries)
+}
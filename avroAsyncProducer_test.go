@@ -0,0 +1,114 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+)
+
+func TestAvroAsyncProducer_Add(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	producerMock := mocks.NewAsyncProducer(t, config)
+	producerMock.ExpectInputAndSucceed()
+	producerMock.ExpectInputAndFail(errors.New("broker down"))
+	schemaRegistryTestObject := createSchemaRegistryTestObject(t, "test", 1)
+	schemaRegistryMock := NewCachedSchemaRegistryClient([]string{schemaRegistryTestObject.MockServer.URL}, nil)
+
+	avroProducer := newAvroAsyncProducer(producerMock, schemaRegistryMock, nil)
+	schema := schemaRegistryTestObject.Codec.Schema()
+	if err := avroProducer.Add("test", schema, []byte(`{"val":1}`), "first"); err != nil {
+		t.Errorf("Error adding msg: %v", err)
+	}
+	if err := avroProducer.AddWithKey("test", StringKey("key"), schema, []byte(`{"val":2}`), "second"); err != nil {
+		t.Errorf("Error adding msg: %v", err)
+	}
+	if err := avroProducer.Add("test", schema, []byte(`{"unknown":true}`), "invalid"); err == nil {
+		t.Errorf("Expected encoding error to be returned by Add")
+	}
+
+	reports := map[interface{}]DeliveryReport{}
+	for i := 0; i < 2; i++ {
+		report := <-avroProducer.Deliveries()
+		reports[report.Metadata] = report
+	}
+	avroProducer.Close()
+	if report := reports["first"]; report.Err != nil || report.Topic != "test" || report.Offset != 1 {
+		t.Errorf("Expected successful delivery of first msg, got %+v", report)
+	}
+	if report := reports["second"]; report.Err == nil {
+		t.Errorf("Expected failed delivery of second msg, got %+v", report)
+	}
+}
+
+func TestAvroAsyncProducer_OnDelivery(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	producerMock := mocks.NewAsyncProducer(t, config)
+	producerMock.ExpectInputAndSucceed()
+	schemaRegistryTestObject := createSchemaRegistryTestObject(t, "test", 1)
+	schemaRegistryMock := NewCachedSchemaRegistryClient([]string{schemaRegistryTestObject.MockServer.URL}, nil)
+
+	var reports []DeliveryReport
+	avroProducer := newAvroAsyncProducer(producerMock, schemaRegistryMock, func(report DeliveryReport) {
+		reports = append(reports, report)
+	})
+	if err := avroProducer.Add("test", schemaRegistryTestObject.Codec.Schema(), []byte(`{"val":1}`), 42); err != nil {
+		t.Errorf("Error adding msg: %v", err)
+	}
+	avroProducer.Close()
+	if len(reports) != 1 || reports[0].Metadata != 42 || reports[0].Err != nil {
+		t.Errorf("Expected one successful delivery report, got %+v", reports)
+	}
+}
+
+func TestAvroAsyncProducer_AddContext(t *testing.T) {
+	producerMock := mocks.NewAsyncProducer(t, nil)
+	registry := NewMockSchemaRegistryClient()
+	avroProducer := newAvroAsyncProducer(producerMock, registry, nil)
+	defer avroProducer.Close()
+	schema := `{"type": "record", "name": "test", "fields" : [{"name": "val", "type": "int", "default": 0}]}`
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := avroProducer.AddContext(ctx, "test", schema, []byte(testData), nil); err != context.Canceled {
+		t.Errorf("Expected the cancelled context error, got %v", err)
+	}
+	if err := avroProducer.AddWithKeyContext(ctx, "test", StringKey("key"), schema, []byte(testData), nil); err != context.Canceled {
+		t.Errorf("Expected the cancelled context error, got %v", err)
+	}
+}
+
+func TestAvroAsyncProducer_CloseUndrained(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	producerMock := mocks.NewAsyncProducer(t, config)
+	registry := NewMockSchemaRegistryClient()
+	avroProducer := newAvroAsyncProducer(producerMock, registry, nil)
+	schema := `{"type": "record", "name": "test", "fields" : [{"name": "val", "type": "int", "default": 0}]}`
+	// more messages than Deliveries() buffers, nobody reads the reports
+	for i := 0; i < 300; i++ {
+		producerMock.ExpectInputAndSucceed()
+		if err := avroProducer.Add("test", schema, []byte(testData), i); err != nil {
+			t.Fatalf("Error adding msg: %v", err)
+		}
+	}
+	closed := make(chan struct{})
+	go func() {
+		avroProducer.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Close blocked on the unread delivery reports")
+	}
+	if err := avroProducer.Add("test", schema, []byte(testData), nil); err != ErrProducerClosed {
+		t.Errorf("Expected ErrProducerClosed adding to a closed producer, got %v", err)
+	}
+	avroProducer.Close()
+}
@@ -1,12 +1,19 @@
 package kafka
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"os"
 	"os/signal"
+	"reflect"
+	"runtime/debug"
+	"sync"
+	"time"
 
 	"github.com/Shopify/sarama"
-	cluster "github.com/bsm/sarama-cluster"
 	"github.com/linkedin/goavro"
 )
 
@@ -17,18 +24,44 @@ type AvroConsumerConfig struct {
 	GroupId               string
 	Callbacks             ConsumerCallbacks
 	SASL                  *SASLConfig
+	// TLS enables TLS on the kafka connection, independently of SASL
+	TLS *tls.Config
+	// SchemaRegistryTLS, SchemaRegistryAuth, SchemaRegistryOptions and SchemaRegistryClient configure
+	// the schema registry client as in AvroProducerConfig
+	SchemaRegistryTLS     *tls.Config
+	SchemaRegistryAuth    SchemaRegistryAuth
+	SchemaRegistryOptions []SchemaRegistryOption
+	SchemaRegistryClient  SchemaRegistryClientInterface
+	// BalanceStrategy assigns partitions to the group members, sarama.BalanceStrategyRange when nil.
+	// sarama.BalanceStrategySticky keeps assignments stable across rebalances, sarama only implements
+	// the eager rebalance protocol so the cooperative-sticky protocol is not available.
+	BalanceStrategy sarama.BalanceStrategy
+	// FailurePolicy is applied when decoding a message or OnMessage fails, by default the partition is stopped
+	FailurePolicy FailurePolicy
+	// DeadLetter sends the messages still failing after the retries of FailurePolicy to a dead letter topic,
+	// it replaces the Action and DeadLetter of FailurePolicy when set
+	DeadLetter *DeadLetterConfig
 }
 
 type avroConsumer struct {
-	Consumer             *cluster.Consumer
-	SchemaRegistryClient *CachedSchemaRegistryClient
+	Consumer             sarama.ConsumerGroup
+	SchemaRegistryClient SchemaRegistryClientInterface
 	callbacks            ConsumerCallbacks
+	topics               []string
+	failurePolicy        FailurePolicy
+	deadLetterProducer   *AvroProducer
 }
 
 type ConsumerCallbacks struct {
+	// OnMessage handles a decoded message, its offset is marked only when it returns nil,
+	// otherwise the FailurePolicy of the consumer is applied. It replaces OnDataReceived when set.
+	OnMessage      func(msg Message) error
 	OnDataReceived func(msg Message)
 	OnError        func(err error)
-	OnNotification func(notification *cluster.Notification)
+	// OnSetup is called when a session starts, once the partitions of session.Claims() are assigned
+	OnSetup func(session sarama.ConsumerGroupSession)
+	// OnCleanup is called when a session ends, before its partitions are revoked by a rebalance
+	OnCleanup func(session sarama.ConsumerGroupSession)
 }
 
 type Message struct {
@@ -38,103 +71,435 @@ type Message struct {
 	Offset    int64
 	Key       string
 	Value     string
+	// KeySchemaId is the schema id of an avro encoded key, 0 when the key is not avro encoded, its schema is not
+	// found or it does not decode
+	KeySchemaId int
+	// KeyNative is the avro encoded key in native Go form, nil when the key is not avro encoded
+	KeyNative interface{}
+	// Native is the value in native Go form
+	Native interface{}
+	codec  *goavro.Codec
+}
+
+// Decode populates v, which must be a pointer, from the value of the message, see UnmarshalNative
+func (m Message) Decode(v interface{}) error {
+	if m.codec == nil {
+		return ErrInvalidAvroMessage
+	}
+	return UnmarshalNative(m.codec, m.Native, v)
+}
+
+// TypedHandler returns an OnDataReceived callback decoding every value into a new instance
+// of T and passing it to fn, which must be a func(Message, *T). Decoding errors are passed to onError.
+func TypedHandler(fn interface{}, onError func(err error)) func(msg Message) {
+	fnType := reflect.TypeOf(fn)
+	if !isTypedHandler(fnType) || fnType.NumOut() != 0 {
+		panic(fmt.Sprintf("kafka: TypedHandler expects a func(Message, *T), got %T", fn))
+	}
+	handler := typedHandler(fn)
+	return func(msg Message) {
+		if err := handler(msg); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
+
+// TypedMessageHandler returns an OnMessage callback decoding every value into a new instance
+// of T and passing it to fn, which must be a func(Message, *T) error. Decoding errors are returned
+// like the errors of fn, so the FailurePolicy of the consumer applies to both.
+func TypedMessageHandler(fn interface{}) func(msg Message) error {
+	fnType := reflect.TypeOf(fn)
+	if !isTypedHandler(fnType) || fnType.NumOut() != 1 || fnType.Out(0) != reflect.TypeOf((*error)(nil)).Elem() {
+		panic(fmt.Sprintf("kafka: TypedMessageHandler expects a func(Message, *T) error, got %T", fn))
+	}
+	return typedHandler(fn)
+}
+
+func isTypedHandler(fnType reflect.Type) bool {
+	return fnType != nil && fnType.Kind() == reflect.Func && fnType.NumIn() == 2 &&
+		fnType.In(0) == reflect.TypeOf(Message{}) && fnType.In(1).Kind() == reflect.Ptr
+}
+
+func typedHandler(fn interface{}) func(msg Message) error {
+	fnValue := reflect.ValueOf(fn)
+	valueType := fnValue.Type().In(1).Elem()
+	return func(msg Message) error {
+		value := reflect.New(valueType)
+		if err := msg.Decode(value.Interface()); err != nil {
+			return err
+		}
+		out := fnValue.Call([]reflect.Value{reflect.ValueOf(msg), value})
+		if len(out) == 1 && !out[0].IsNil() {
+			return out[0].Interface().(error)
+		}
+		return nil
+	}
 }
 
 // avroConsumer is a basic consumer to interact with schema registry, avro and kafka
 func NewAvroConsumer(cfg AvroConsumerConfig) (*avroConsumer, error) {
-	// init (custom) config, enable errors and notifications
-	config := cluster.NewConfig()
+	// init (custom) config, enable errors
+	config := sarama.NewConfig()
 	config.Consumer.Return.Errors = true
-	config.Group.Return.Notifications = true
 	//read from beginning at the first time
 	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	if cfg.BalanceStrategy != nil {
+		config.Consumer.Group.Rebalance.Strategy = cfg.BalanceStrategy
+	}
+	setBrokerSecurity(config, cfg.SASL, cfg.TLS)
 	topics := []string{cfg.Topic}
-	consumer, err := cluster.NewConsumer(cfg.KafkaServers, cfg.GroupId, topics, config)
+	consumer, err := sarama.NewConsumerGroup(cfg.KafkaServers, cfg.GroupId, config)
 	if err != nil {
 		return nil, err
 	}
 
-	schemaRegistryClient := NewCachedSchemaRegistryClient(cfg.SchemaRegistryServers, cfg.SASL)
+	schemaRegistryClient := schemaRegistrySettings{
+		servers: cfg.SchemaRegistryServers,
+		sasl:    cfg.SASL,
+		tls:     cfg.SchemaRegistryTLS,
+		auth:    cfg.SchemaRegistryAuth,
+		options: cfg.SchemaRegistryOptions,
+		client:  cfg.SchemaRegistryClient,
+	}.newClient()
+
+	failurePolicy := cfg.FailurePolicy
+	var deadLetterProducer *AvroProducer
+	if cfg.DeadLetter != nil {
+		deadLetterProducer, err = NewAvroProducer(AvroProducerConfig{
+			KafkaServers:         cfg.KafkaServers,
+			SASL:                 cfg.SASL,
+			TLS:                  cfg.TLS,
+			SchemaRegistryClient: schemaRegistryClient,
+		})
+		if err != nil {
+			consumer.Close()
+			return nil, err
+		}
+		failurePolicy.Action = FailureDeadLetter
+		failurePolicy.DeadLetter = deadLetter(deadLetterProducer, cfg.DeadLetter.Topic)
+	}
+
 	return &avroConsumer{
 		consumer,
 		schemaRegistryClient,
 		cfg.Callbacks,
+		topics,
+		failurePolicy,
+		deadLetterProducer,
 	}, nil
 }
 
 // GetSchemaId get schema id from schema-registry service
 func (ac *avroConsumer) GetSchema(id int) (*goavro.Codec, error) {
-	codec, err := ac.SchemaRegistryClient.GetSchema(id)
+	return ac.getSchema(context.Background(), id)
+}
+
+func (ac *avroConsumer) getSchema(ctx context.Context, id int) (*goavro.Codec, error) {
+	codec, err := ac.SchemaRegistryClient.GetSchemaContext(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 	return codec, nil
 }
 
+// Consume runs the consumer until SIGINT is received, the error returned by Run is reported to OnError.
+// Run lets the caller control the lifecycle with a context instead.
 func (ac *avroConsumer) Consume() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	// trap SIGINT to trigger a shutdown.
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, os.Interrupt)
-
-	// consume errors
+	defer signal.Stop(signals)
 	go func() {
-		for err := range ac.Consumer.Errors() {
-			if ac.callbacks.OnError != nil {
-				ac.callbacks.OnError(err)
-			}
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
 		}
 	}()
 
-	// consume notifications
+	if err := ac.Run(ctx); err != nil {
+		ac.onError(err)
+	}
+}
+
+// Run consumes until ctx is cancelled, the consumer is closed or joining the group fails permanently.
+// The handlers in flight finish first, then their offsets are committed and the group is closed.
+// Errors of single messages and partitions and transient failures to join the group are reported to OnError,
+// a permanent failure, e.g. a denied authorization or invalid credentials, or else the error of closing
+// the group is returned.
+func (ac *avroConsumer) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	wg.Add(1)
 	go func() {
-		for notification := range ac.Consumer.Notifications() {
-			if ac.callbacks.OnNotification != nil {
-				ac.callbacks.OnNotification(notification)
-			}
+		defer wg.Done()
+		for err := range ac.Consumer.Errors() {
+			ac.onError(err)
 		}
 	}()
 
+	consumeErr := ac.consume(ctx)
+	err := ac.Consumer.Close()
+	wg.Wait()
+	if consumeErr != nil {
+		return consumeErr
+	}
+	if err == sarama.ErrClosedConsumerGroup {
+		return nil
+	}
+	return err
+}
+
+// rejoinBackoff is the delay before joining the group again after Consume failed
+var rejoinBackoff = FailurePolicy{Backoff: 100 * time.Millisecond, MaxBackoff: 10 * time.Second}
+
+// consume joins the group again after every rebalance, which ends the session, until ctx is cancelled
+// or the group is closed. Transient failures to join, e.g. while brokers restart, are reported to OnError
+// and retried, permanent ones are returned.
+func (ac *avroConsumer) consume(ctx context.Context) error {
+	handler := &avroConsumerGroupHandler{ac}
+	failures := 0
 	for {
+		err := ac.Consumer.Consume(ctx, ac.topics, handler)
+		if err == sarama.ErrClosedConsumerGroup || ctx.Err() != nil {
+			return nil
+		}
+		if err == nil {
+			failures = 0
+			continue
+		}
+		if isPermanentConsumeError(err) {
+			return err
+		}
+		ac.onError(err)
+		failures++
 		select {
-		case m, ok := <-ac.Consumer.Messages():
-			if ok {
-				msg, err := ac.ProcessAvroMsg(m)
-				if err != nil {
-					ac.callbacks.OnError(err)
-				}
-				ac.Consumer.MarkOffset(m, "")
-				if ac.callbacks.OnDataReceived != nil {
-					ac.callbacks.OnDataReceived(msg)
-				}
+		case <-time.After(rejoinBackoff.backoff(failures)):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// isPermanentConsumeError reports whether joining the group cannot succeed without a change of
+// the configuration or of the permissions of the consumer
+func isPermanentConsumeError(err error) bool {
+	var configErr sarama.ConfigurationError
+	if errors.As(err, &configErr) {
+		return true
+	}
+	var kafkaErr sarama.KError
+	if !errors.As(err, &kafkaErr) {
+		return false
+	}
+	switch kafkaErr {
+	case sarama.ErrTopicAuthorizationFailed, sarama.ErrGroupAuthorizationFailed, sarama.ErrClusterAuthorizationFailed,
+		sarama.ErrInvalidTopic, sarama.ErrSASLAuthenticationFailed, sarama.ErrUnsupportedSASLMechanism,
+		sarama.ErrIllegalSASLState, sarama.ErrInvalidGroupId:
+		return true
+	}
+	return false
+}
+
+func (ac *avroConsumer) onError(err error) {
+	if ac.callbacks.OnError != nil {
+		ac.callbacks.OnError(err)
+	}
+}
+
+// avroConsumerGroupHandler decodes the messages of the claimed partitions
+type avroConsumerGroupHandler struct {
+	consumer *avroConsumer
+}
+
+func (h *avroConsumerGroupHandler) Setup(session sarama.ConsumerGroupSession) error {
+	if h.consumer.callbacks.OnSetup != nil {
+		h.consumer.callbacks.OnSetup(session)
+	}
+	return nil
+}
+
+func (h *avroConsumerGroupHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	if h.consumer.callbacks.OnCleanup != nil {
+		h.consumer.callbacks.OnCleanup(session)
+	}
+	// the handlers are done, commit their offsets before the partitions are revoked
+	session.Commit()
+	return nil
+}
+
+func (h *avroConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	ac := h.consumer
+	for {
+		select {
+		case m, ok := <-claim.Messages():
+			if !ok {
+				return nil
 			}
-		case <-signals:
-			return
+			if !ac.handle(session, m) {
+				// returning would end the session of every partition, so wait for the next rebalance
+				<-session.Context().Done()
+				return nil
+			}
+		case <-session.Context().Done():
+			// stop after the message in flight, the buffered ones are consumed again by the next session
+			return nil
+		}
+	}
+}
+
+// handle decodes and handles m, applying the failure policy when that fails.
+// The offset of m is marked only once it is handled, false is returned when the partition must stop.
+func (ac *avroConsumer) handle(session sarama.ConsumerGroupSession, m *sarama.ConsumerMessage) bool {
+	policy := ac.failurePolicy
+	err := ac.handleMessage(session.Context(), m)
+	for retry := 1; err != nil && retry <= policy.Retries; retry++ {
+		select {
+		case <-time.After(policy.backoff(retry)):
+		case <-session.Context().Done():
+			return false
+		}
+		err = ac.handleMessage(session.Context(), m)
+	}
+	if err == nil {
+		session.MarkMessage(m, "")
+		return true
+	}
+	if session.Context().Err() != nil {
+		// the registry calls were cancelled by the end of the session, the message is consumed again
+		return false
+	}
+
+	ac.onError(err)
+	switch policy.Action {
+	case FailureSkip:
+		session.MarkMessage(m, "")
+		return true
+	case FailureDeadLetter:
+		if policy.DeadLetter == nil {
+			ac.onError(fmt.Errorf("no dead letter handler for %s/%d at offset %d", m.Topic, m.Partition, m.Offset))
+			return false
 		}
+		if err := policy.DeadLetter(m, err); err != nil {
+			ac.onError(err)
+			return false
+		}
+		session.MarkMessage(m, "")
+		return true
 	}
+	return false
 }
 
+// handleMessage decodes and handles m, a panicking handler returns a *PanicError
+func (ac *avroConsumer) handleMessage(ctx context.Context, m *sarama.ConsumerMessage) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{r, debug.Stack()}
+		}
+	}()
+	msg, err := ac.processAvroMsg(ctx, m)
+	if err != nil {
+		return err
+	}
+	if ac.callbacks.OnMessage != nil {
+		return ac.callbacks.OnMessage(msg)
+	}
+	if ac.callbacks.OnDataReceived != nil {
+		ac.callbacks.OnDataReceived(msg)
+	}
+	return nil
+}
+
+// ProcessAvroMsg decodes m, the schemas are fetched with context.Background()
 func (ac *avroConsumer) ProcessAvroMsg(m *sarama.ConsumerMessage) (Message, error) {
-	schemaId := binary.BigEndian.Uint32(m.Value[1:5])
-	codec, err := ac.GetSchema(int(schemaId))
+	return ac.processAvroMsg(context.Background(), m)
+}
+
+func (ac *avroConsumer) processAvroMsg(ctx context.Context, m *sarama.ConsumerMessage) (Message, error) {
+	schemaId, codec, native, textual, err := ac.decodeAvro(ctx, m.Value)
 	if err != nil {
 		return Message{}, err
 	}
+	msg := Message{
+		SchemaId:  schemaId,
+		Topic:     m.Topic,
+		Partition: m.Partition,
+		Offset:    m.Offset,
+		Key:       string(m.Key),
+		Value:     string(textual),
+		Native:    native,
+		codec:     codec,
+	}
+	// keys written by kafka connect or ksqlDB carry the same header as values, raw binary keys like
+	// big endian longs may start with the same bytes. A key whose schema is not found or which does
+	// not decode is kept as is, other registry errors are returned so the failure policy applies.
+	if isAvroEncoded(m.Key) {
+		keySchemaId := int(binary.BigEndian.Uint32(m.Key[1:5]))
+		keyCodec, err := ac.getSchema(ctx, keySchemaId)
+		if err != nil && !isSchemaNotFound(err) {
+			return Message{}, err
+		}
+		if err == nil {
+			if keyNative, textualKey, err := decodeAvroWith(keyCodec, m.Key); err == nil {
+				msg.Key = string(textualKey)
+				msg.KeySchemaId = keySchemaId
+				msg.KeyNative = keyNative
+			}
+		}
+	}
+	return msg, nil
+}
+
+// decodeAvro converts data in the confluent wire format to native Go form and textual avro data,
+// the codec of its schema is returned with them
+func (ac *avroConsumer) decodeAvro(ctx context.Context, data []byte) (int, *goavro.Codec, interface{}, []byte, error) {
+	if !isAvroEncoded(data) {
+		return 0, nil, nil, nil, ErrInvalidAvroMessage
+	}
+	schemaId := int(binary.BigEndian.Uint32(data[1:5]))
+	codec, err := ac.getSchema(ctx, schemaId)
+	if err != nil {
+		return 0, nil, nil, nil, err
+	}
+	native, textual, err := decodeAvroWith(codec, data)
+	if err != nil {
+		return 0, nil, nil, nil, err
+	}
+	return schemaId, codec, native, textual, nil
+}
+
+// decodeAvroWith decodes data in the confluent wire format with the codec of its schema
+func decodeAvroWith(codec *goavro.Codec, data []byte) (interface{}, []byte, error) {
 	// Convert binary Avro data back to native Go form
-	native, _, err := codec.NativeFromBinary(m.Value[5:])
+	native, _, err := codec.NativeFromBinary(data[5:])
 	if err != nil {
-		return Message{}, err
+		return nil, nil, err
 	}
 
 	// Convert native Go form to textual Avro data
 	textual, err := codec.TextualFromNative(nil, native)
 
 	if err != nil {
-		return Message{}, err
+		return nil, nil, err
 	}
-	msg := Message{int(schemaId), m.Topic, m.Partition, m.Offset, string(m.Key), string(textual)}
-	return msg, nil
+	return native, textual, nil
+}
+
+// isSchemaNotFound reports whether err is the schema registry error for an unknown schema id
+func isSchemaNotFound(err error) bool {
+	var registryErr *Error
+	return errors.As(err, &registryErr) && registryErr.ErrorCode == 40403
+}
+
+// isAvroEncoded reports whether data starts with the magic byte and schema id header
+func isAvroEncoded(data []byte) bool {
+	return len(data) >= 5 && data[0] == 0
 }
 
 func (ac *avroConsumer) Close() {
 	ac.Consumer.Close()
+	if ac.deadLetterProducer != nil {
+		ac.deadLetterProducer.Close()
+	}
 }
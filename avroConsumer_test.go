@@ -1,8 +1,15 @@
 package kafka
 
 import (
+	"context"
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/Shopify/sarama"
 	"github.com/linkedin/goavro"
@@ -32,7 +39,7 @@ func TestAvroConsumer_ProcessAvroMsg(t *testing.T) {
 	schemaRegistryTestObject := createSchemaRegistryTestObject(t, "test", 1)
 	schemaRegistryMock := NewCachedSchemaRegistryClient([]string{schemaRegistryTestObject.MockServer.URL}, saslConfig)
 	callbacks := &ConsumerCallbacks{}
-	avroConsumer := &avroConsumer{nil, schemaRegistryMock, *callbacks}
+	avroConsumer := &avroConsumer{nil, schemaRegistryMock, *callbacks, nil, FailurePolicy{}, nil}
 	consumerMsg := &sarama.ConsumerMessage{
 		Value:     getTestAvroMsg(t, schemaRegistryTestObject.Codec),
 		Key:       []byte("key"),
@@ -47,4 +54,437 @@ func TestAvroConsumer_ProcessAvroMsg(t *testing.T) {
 	if msg.Value != testData {
 		t.Errorf("Wrong data")
 	}
+	if msg.Key != "key" {
+		t.Errorf("Wrong key, expected: key, got: %s", msg.Key)
+	}
+	if msg.KeySchemaId != 0 || msg.KeyNative != nil {
+		t.Errorf("Expected raw key without schema, got schema id %d", msg.KeySchemaId)
+	}
+}
+
+func TestAvroConsumer_ProcessAvroMsgFetchesSchemaOnce(t *testing.T) {
+	schemaRegistryTestObject := createSchemaRegistryTestObject(t, "test", 1)
+	schemaRegistryClient := NewSchemaRegistryClient([]string{schemaRegistryTestObject.MockServer.URL}, nil)
+	avroConsumer := &avroConsumer{nil, schemaRegistryClient, ConsumerCallbacks{}, nil, FailurePolicy{}, nil}
+	consumerMsg := &sarama.ConsumerMessage{Value: getTestAvroMsg(t, schemaRegistryTestObject.Codec), Topic: "test"}
+	if _, err := avroConsumer.ProcessAvroMsg(consumerMsg); err != nil {
+		t.Errorf("Error process avro msg: %v", err)
+	}
+	if schemaRegistryTestObject.Count != 1 {
+		t.Errorf("Expected the value schema to be fetched once, got %d requests", schemaRegistryTestObject.Count)
+	}
+}
+
+func TestAvroConsumer_ProcessAvroMsgWithAvroKey(t *testing.T) {
+	schemaRegistryTestObject := createSchemaRegistryTestObject(t, "test", 1)
+	schemaRegistryMock := NewCachedSchemaRegistryClient([]string{schemaRegistryTestObject.MockServer.URL}, nil)
+	avroConsumer := &avroConsumer{nil, schemaRegistryMock, ConsumerCallbacks{}, nil, FailurePolicy{}, nil}
+	consumerMsg := &sarama.ConsumerMessage{
+		Value: getTestAvroMsg(t, schemaRegistryTestObject.Codec),
+		Key:   getTestAvroMsg(t, schemaRegistryTestObject.Codec),
+		Topic: "test",
+	}
+	msg, err := avroConsumer.ProcessAvroMsg(consumerMsg)
+	if err != nil {
+		t.Errorf("Error process avro msg: %v", err)
+	}
+	if msg.Key != testData {
+		t.Errorf("Wrong key, expected: %s, got: %s", testData, msg.Key)
+	}
+	if msg.KeySchemaId != 1 {
+		t.Errorf("Wrong key schema id, expected: 1, got: %d", msg.KeySchemaId)
+	}
+	keyNative, ok := msg.KeyNative.(map[string]interface{})
+	if !ok || keyNative["val"] != int32(1) {
+		t.Errorf("Wrong native key, got: %v", msg.KeyNative)
+	}
+}
+
+func TestAvroConsumer_ProcessAvroMsgWithLongKey(t *testing.T) {
+	schemaRegistryTestObject := createSchemaRegistryTestObject(t, "test", 1)
+	schemaRegistryMock := NewCachedSchemaRegistryClient([]string{schemaRegistryTestObject.MockServer.URL}, nil)
+	avroConsumer := &avroConsumer{nil, schemaRegistryMock, ConsumerCallbacks{}, nil, FailurePolicy{}, nil}
+	// a key written by a LongSerializer starts with the magic byte like an avro key
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, 42)
+	consumerMsg := &sarama.ConsumerMessage{
+		Value: getTestAvroMsg(t, schemaRegistryTestObject.Codec),
+		Key:   key,
+		Topic: "test",
+	}
+	msg, err := avroConsumer.ProcessAvroMsg(consumerMsg)
+	if err != nil {
+		t.Errorf("Error process avro msg: %v", err)
+	}
+	if msg.Key != string(key) || msg.KeySchemaId != 0 || msg.KeyNative != nil {
+		t.Errorf("Expected the raw key, got %q with schema id %d", msg.Key, msg.KeySchemaId)
+	}
+	if msg.Value != testData {
+		t.Errorf("Wrong data")
+	}
+}
+
+func TestAvroConsumer_ProcessAvroMsgKeyRegistryError(t *testing.T) {
+	schemaRegistryTestObject := createSchemaRegistryTestObject(t, "test", 1)
+	value := getTestAvroMsg(t, schemaRegistryTestObject.Codec)
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == fmt.Sprintf(schemaByID, 1) {
+			schemaRegistryTestObject.MockServer.Config.Handler.ServeHTTP(w, r)
+			return
+		}
+		http.Error(w, `{"error_code": 50001, "message": "Error in the backend datastore"}`, 500)
+	}))
+	defer mockServer.Close()
+	schemaRegistryClient := NewSchemaRegistryClientWithOptions([]string{mockServer.URL}, WithRetries(0))
+	avroConsumer := &avroConsumer{nil, schemaRegistryClient, ConsumerCallbacks{}, nil, FailurePolicy{}, nil}
+	key := append([]byte{0, 0, 0, 0, 7}, value[5:]...)
+	_, err := avroConsumer.ProcessAvroMsg(&sarama.ConsumerMessage{Value: value, Key: key, Topic: "test"})
+	var registryErr *Error
+	if !errors.As(err, &registryErr) || registryErr.ErrorCode != 50001 {
+		t.Errorf("Expected the registry error of the key schema, got %v", err)
+	}
+}
+
+func TestTypedHandler(t *testing.T) {
+	schemaRegistryTestObject := createSchemaRegistryTestObject(t, "test", 1)
+	schemaRegistryMock := NewCachedSchemaRegistryClient([]string{schemaRegistryTestObject.MockServer.URL}, nil)
+	avroConsumer := &avroConsumer{nil, schemaRegistryMock, ConsumerCallbacks{}, nil, FailurePolicy{}, nil}
+	msg, err := avroConsumer.ProcessAvroMsg(&sarama.ConsumerMessage{
+		Value: getTestAvroMsg(t, schemaRegistryTestObject.Codec),
+		Topic: "test",
+	})
+	if err != nil {
+		t.Fatalf("Error process avro msg: %v", err)
+	}
+	type testValue struct {
+		Val int `avro:"val"`
+	}
+	var received *testValue
+	handler := TypedHandler(func(msg Message, value *testValue) {
+		received = value
+	}, func(err error) {
+		t.Errorf("Error decoding value: %v", err)
+	})
+	handler(msg)
+	if received == nil || received.Val != 1 {
+		t.Errorf("Expected decoded value with val 1, got %+v", received)
+	}
+}
+
+type testConsumerGroupSession struct {
+	ctx       context.Context
+	marked    []int64
+	committed int
+}
+
+func (s *testConsumerGroupSession) Claims() map[string][]int32 {
+	return map[string][]int32{"test": {0}}
+}
+func (s *testConsumerGroupSession) MemberID() string    { return "member" }
+func (s *testConsumerGroupSession) GenerationID() int32 { return 1 }
+func (s *testConsumerGroupSession) MarkOffset(topic string, partition int32, offset int64, metadata string) {
+	s.marked = append(s.marked, offset)
+}
+func (s *testConsumerGroupSession) Commit() { s.committed++ }
+func (s *testConsumerGroupSession) ResetOffset(topic string, partition int32, offset int64, metadata string) {
+}
+func (s *testConsumerGroupSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.MarkOffset(msg.Topic, msg.Partition, msg.Offset+1, metadata)
+}
+func (s *testConsumerGroupSession) Context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+type testConsumerGroupClaim struct {
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c *testConsumerGroupClaim) Topic() string                            { return "test" }
+func (c *testConsumerGroupClaim) Partition() int32                         { return 0 }
+func (c *testConsumerGroupClaim) InitialOffset() int64                     { return 0 }
+func (c *testConsumerGroupClaim) HighWaterMarkOffset() int64               { return int64(len(c.messages)) }
+func (c *testConsumerGroupClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+func newTestConsumerGroupClaim(messages ...*sarama.ConsumerMessage) *testConsumerGroupClaim {
+	claim := &testConsumerGroupClaim{make(chan *sarama.ConsumerMessage, len(messages))}
+	for _, m := range messages {
+		claim.messages <- m
+	}
+	close(claim.messages)
+	return claim
+}
+
+func TestAvroConsumerGroupHandler(t *testing.T) {
+	schemaRegistryTestObject := createSchemaRegistryTestObject(t, "test", 1)
+	schemaRegistryMock := NewCachedSchemaRegistryClient([]string{schemaRegistryTestObject.MockServer.URL}, nil)
+	var received []Message
+	var setup, cleanup int
+	callbacks := ConsumerCallbacks{
+		OnDataReceived: func(msg Message) { received = append(received, msg) },
+		OnSetup:        func(session sarama.ConsumerGroupSession) { setup++ },
+		OnCleanup:      func(session sarama.ConsumerGroupSession) { cleanup++ },
+	}
+	handler := &avroConsumerGroupHandler{&avroConsumer{nil, schemaRegistryMock, callbacks, []string{"test"}, FailurePolicy{}, nil}}
+	session := &testConsumerGroupSession{}
+	claim := newTestConsumerGroupClaim(
+		&sarama.ConsumerMessage{Value: getTestAvroMsg(t, schemaRegistryTestObject.Codec), Topic: "test", Offset: 0},
+		&sarama.ConsumerMessage{Value: getTestAvroMsg(t, schemaRegistryTestObject.Codec), Topic: "test", Offset: 1},
+	)
+
+	if err := handler.Setup(session); err != nil {
+		t.Errorf("Error in setup: %v", err)
+	}
+	if err := handler.ConsumeClaim(session, claim); err != nil {
+		t.Errorf("Error consuming claim: %v", err)
+	}
+	if err := handler.Cleanup(session); err != nil {
+		t.Errorf("Error in cleanup: %v", err)
+	}
+	if len(received) != 2 || received[1].Value != testData {
+		t.Errorf("Expected 2 messages, got %v", received)
+	}
+	if !reflect.DeepEqual(session.marked, []int64{1, 2}) {
+		t.Errorf("Expected offsets 1 and 2 to be marked, got %v", session.marked)
+	}
+	if setup != 1 || cleanup != 1 {
+		t.Errorf("Expected setup and cleanup to be called once, got %d and %d", setup, cleanup)
+	}
+}
+
+func TestAvroConsumer_FailurePolicy(t *testing.T) {
+	schemaRegistryTestObject := createSchemaRegistryTestObject(t, "test", 1)
+	schemaRegistryMock := NewCachedSchemaRegistryClient([]string{schemaRegistryTestObject.MockServer.URL}, nil)
+	handlerErr := errors.New("handler failed")
+	newClaim := func() *testConsumerGroupClaim {
+		return newTestConsumerGroupClaim(
+			&sarama.ConsumerMessage{Value: []byte("not avro"), Topic: "test", Offset: 0},
+			&sarama.ConsumerMessage{Value: getTestAvroMsg(t, schemaRegistryTestObject.Codec), Topic: "test", Offset: 1},
+			&sarama.ConsumerMessage{Value: getTestAvroMsg(t, schemaRegistryTestObject.Codec), Topic: "test", Offset: 2},
+		)
+	}
+	// the message at offset 1 fails twice before it is handled
+	newCallbacks := func(calls *int, errs *[]error, onError func()) ConsumerCallbacks {
+		return ConsumerCallbacks{
+			OnMessage: func(msg Message) error {
+				if msg.Offset == 1 {
+					*calls++
+					if *calls <= 2 {
+						return handlerErr
+					}
+				}
+				return nil
+			},
+			OnError: func(err error) {
+				*errs = append(*errs, err)
+				onError()
+			},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		policy     FailurePolicy
+		marked     []int64
+		deadLetter []int64
+	}{
+		{"default", FailurePolicy{}, nil, nil},
+		{"skip", FailurePolicy{Action: FailureSkip}, []int64{1, 2, 3}, nil},
+		{"retry", FailurePolicy{Retries: 2, Backoff: time.Millisecond, Action: FailureSkip}, []int64{1, 2, 3}, nil},
+		{"stop partition", FailurePolicy{Action: FailureStopPartition}, nil, nil},
+		{"dead letter", FailurePolicy{Action: FailureDeadLetter}, []int64{1, 2, 3}, []int64{0, 1}},
+	}
+	for _, test := range tests {
+		var calls int
+		var errs []error
+		var deadLetter []int64
+		policy := test.policy
+		if policy.Action == FailureDeadLetter {
+			policy.DeadLetter = func(m *sarama.ConsumerMessage, err error) error {
+				deadLetter = append(deadLetter, m.Offset)
+				return nil
+			}
+		}
+		// a stopped partition waits for the end of the session, which the first error ends
+		ctx, cancel := context.WithCancel(context.Background())
+		onError := func() {}
+		if policy.Action == FailureStopPartition {
+			onError = cancel
+		}
+		session := &testConsumerGroupSession{ctx: ctx}
+		handler := &avroConsumerGroupHandler{&avroConsumer{nil, schemaRegistryMock, newCallbacks(&calls, &errs, onError), []string{"test"}, policy, nil}}
+		if err := handler.ConsumeClaim(session, newClaim()); err != nil {
+			t.Errorf("%s: error consuming claim: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(session.marked, test.marked) {
+			t.Errorf("%s: expected marked offsets %v, got %v", test.name, test.marked, session.marked)
+		}
+		if !reflect.DeepEqual(deadLetter, test.deadLetter) {
+			t.Errorf("%s: expected dead letter offsets %v, got %v", test.name, test.deadLetter, deadLetter)
+		}
+		if len(errs) == 0 || errs[0] != ErrInvalidAvroMessage {
+			t.Errorf("%s: expected the decoding error to be reported, got %v", test.name, errs)
+		}
+		cancel()
+	}
+}
+
+func TestTypedMessageHandler(t *testing.T) {
+	schemaRegistryTestObject := createSchemaRegistryTestObject(t, "test", 1)
+	schemaRegistryMock := NewCachedSchemaRegistryClient([]string{schemaRegistryTestObject.MockServer.URL}, nil)
+	avroConsumer := &avroConsumer{nil, schemaRegistryMock, ConsumerCallbacks{}, nil, FailurePolicy{}, nil}
+	msg, err := avroConsumer.ProcessAvroMsg(&sarama.ConsumerMessage{Value: getTestAvroMsg(t, schemaRegistryTestObject.Codec)})
+	if err != nil {
+		t.Fatalf("Error processing message: %v", err)
+	}
+
+	type testValue struct {
+		Val int32 `avro:"val"`
+	}
+	handlerErr := errors.New("handler failed")
+	var got *testValue
+	handler := TypedMessageHandler(func(msg Message, value *testValue) error {
+		got = value
+		return handlerErr
+	})
+	if err := handler(msg); err != handlerErr {
+		t.Errorf("Expected the handler error, got %v", err)
+	}
+	if got == nil || got.Val != 1 {
+		t.Errorf("Expected the decoded value, got %v", got)
+	}
+	if err := handler(Message{}); err != ErrInvalidAvroMessage {
+		t.Errorf("Expected the decoding error, got %v", err)
+	}
+}
+
+// testConsumerGroup runs a single session over messages, then waits for the end of the context
+type testConsumerGroup struct {
+	messages []*sarama.ConsumerMessage
+	session  *testConsumerGroupSession
+	// errs are returned by the first calls of Consume
+	errs   []error
+	errors chan error
+	closed bool
+}
+
+func newTestConsumerGroup(messages ...*sarama.ConsumerMessage) *testConsumerGroup {
+	return &testConsumerGroup{messages: messages, errors: make(chan error, 1)}
+}
+
+func (g *testConsumerGroup) Consume(ctx context.Context, topics []string, handler sarama.ConsumerGroupHandler) error {
+	if len(g.errs) > 0 {
+		err := g.errs[0]
+		g.errs = g.errs[1:]
+		return err
+	}
+	g.session = &testConsumerGroupSession{ctx: ctx}
+	handler.Setup(g.session)
+	handler.ConsumeClaim(g.session, newTestConsumerGroupClaim(g.messages...))
+	<-ctx.Done()
+	return handler.Cleanup(g.session)
+}
+
+func (g *testConsumerGroup) Errors() <-chan error { return g.errors }
+
+func (g *testConsumerGroup) Close() error {
+	g.closed = true
+	close(g.errors)
+	return nil
+}
+
+func TestAvroConsumer_Run(t *testing.T) {
+	schemaRegistryTestObject := createSchemaRegistryTestObject(t, "test", 1)
+	schemaRegistryMock := NewCachedSchemaRegistryClient([]string{schemaRegistryTestObject.MockServer.URL}, nil)
+	group := newTestConsumerGroup(&sarama.ConsumerMessage{Value: getTestAvroMsg(t, schemaRegistryTestObject.Codec), Topic: "test"})
+	ctx, cancel := context.WithCancel(context.Background())
+	var received int
+	callbacks := ConsumerCallbacks{
+		OnMessage: func(msg Message) error {
+			received++
+			cancel()
+			return nil
+		},
+	}
+	ac := &avroConsumer{group, schemaRegistryMock, callbacks, []string{"test"}, FailurePolicy{}, nil}
+
+	if err := ac.Run(ctx); err != nil {
+		t.Errorf("Expected no error on cancellation, got %v", err)
+	}
+	if received != 1 || len(group.session.marked) != 1 {
+		t.Errorf("Expected one handled and marked message, got %d and %v", received, group.session.marked)
+	}
+	if group.session.committed != 1 {
+		t.Errorf("Expected the offsets to be committed once, got %d", group.session.committed)
+	}
+	if !group.closed {
+		t.Errorf("Expected the group to be closed")
+	}
+}
+
+func TestAvroConsumer_RunError(t *testing.T) {
+	schemaRegistryTestObject := createSchemaRegistryTestObject(t, "test", 1)
+	schemaRegistryMock := NewCachedSchemaRegistryClient([]string{schemaRegistryTestObject.MockServer.URL}, nil)
+	group := newTestConsumerGroup(&sarama.ConsumerMessage{Value: getTestAvroMsg(t, schemaRegistryTestObject.Codec), Topic: "test"})
+	failure := errors.New("rebalance failed")
+	group.errs = []error{failure, failure}
+	ctx, cancel := context.WithCancel(context.Background())
+	var errs []error
+	var received int
+	callbacks := ConsumerCallbacks{
+		OnMessage: func(msg Message) error {
+			received++
+			cancel()
+			return nil
+		},
+		OnError: func(err error) {
+			errs = append(errs, err)
+		},
+	}
+	ac := &avroConsumer{group, schemaRegistryMock, callbacks, []string{"test"}, FailurePolicy{}, nil}
+
+	if err := ac.Run(ctx); err != nil {
+		t.Errorf("Expected no error on cancellation, got %v", err)
+	}
+	if len(errs) != 2 || errs[0] != failure {
+		t.Errorf("Expected the failures to join to be reported, got %v", errs)
+	}
+	if received != 1 {
+		t.Errorf("Expected the group to be joined again after the failures, got %d messages", received)
+	}
+}
+
+func TestAvroConsumer_RunPermanentError(t *testing.T) {
+	group := newTestConsumerGroup()
+	group.errs = []error{errors.New("broker restarting"), sarama.ErrTopicAuthorizationFailed}
+	var errs []error
+	callbacks := ConsumerCallbacks{OnError: func(err error) {
+		errs = append(errs, err)
+	}}
+	ac := &avroConsumer{group, nil, callbacks, []string{"test"}, FailurePolicy{}, nil}
+
+	if err := ac.Run(context.Background()); err != sarama.ErrTopicAuthorizationFailed {
+		t.Errorf("Expected the authorization error to be returned, got %v", err)
+	}
+	if len(errs) != 1 {
+		t.Errorf("Expected only the transient failure to be reported, got %v", errs)
+	}
+	if !group.closed {
+		t.Errorf("Expected the group to be closed")
+	}
+}
+
+func TestAvroConsumer_RunClosed(t *testing.T) {
+	group := newTestConsumerGroup()
+	group.errs = []error{sarama.ErrClosedConsumerGroup}
+	ac := &avroConsumer{group, nil, ConsumerCallbacks{}, []string{"test"}, FailurePolicy{}, nil}
+
+	if err := ac.Run(context.Background()); err != nil {
+		t.Errorf("Expected no error when the group is closed, got %v", err)
+	}
+	if !group.closed {
+		t.Errorf("Expected the group to be closed")
+	}
 }
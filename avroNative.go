@@ -0,0 +1,416 @@
+package kafka
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/ihsanul14/go-confluent-kafka/internal/avroschema"
+	"github.com/linkedin/goavro"
+)
+
+// AvroSchemaProvider is implemented by types that know the avro schema they are encoded with
+type AvroSchemaProvider interface {
+	AvroSchema() string
+}
+
+// AvroMarshaler is implemented by types that convert themselves to the goavro native form
+type AvroMarshaler interface {
+	MarshalAvro() (interface{}, error)
+}
+
+// AvroUnmarshaler is implemented by types that populate themselves from the goavro native form
+type AvroUnmarshaler interface {
+	UnmarshalAvro(native interface{}) error
+}
+
+// MarshalNative converts v to the goavro native form of the schema of codec.
+// Struct fields are matched by their avro tag or by their name, pointers are used for
+// nullable unions and time.Time, time.Duration and *big.Rat for logical types.
+func MarshalNative(codec *goavro.Codec, v interface{}) (interface{}, error) {
+	if m, ok := v.(AvroMarshaler); ok {
+		return m.MarshalAvro()
+	}
+	schema, err := parseNativeSchema(codec)
+	if err != nil {
+		return nil, err
+	}
+	return toNative(schema, reflect.ValueOf(v))
+}
+
+// UnmarshalNative populates v, which must be a pointer, from the goavro native form of the schema of codec
+func UnmarshalNative(codec *goavro.Codec, native interface{}, v interface{}) error {
+	if u, ok := v.(AvroUnmarshaler); ok {
+		return u.UnmarshalAvro(native)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("cannot unmarshal avro into non-pointer %T", v)
+	}
+	schema, err := parseNativeSchema(codec)
+	if err != nil {
+		return err
+	}
+	return fromNative(schema, native, rv.Elem())
+}
+
+var nativeSchemaCache sync.Map
+
+func parseNativeSchema(codec *goavro.Codec) (*avroschema.Schema, error) {
+	return parseSchemaJson(codec.Schema())
+}
+
+// parseSchemaJson parses and caches the avro schema in its json form, it is used to map Go values
+// to the goavro native form and to check the compatibility of schemas
+func parseSchemaJson(schemaJson string) (*avroschema.Schema, error) {
+	if cached, ok := nativeSchemaCache.Load(schemaJson); ok {
+		return cached.(*avroschema.Schema), nil
+	}
+	schema, err := avroschema.Parse([]byte(schemaJson))
+	if err != nil {
+		return nil, err
+	}
+	nativeSchemaCache.Store(schemaJson, schema)
+	return schema, nil
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	ratType      = reflect.TypeOf(&big.Rat{})
+)
+
+// toNative converts a Go value to the goavro native form of schema
+func toNative(schema *avroschema.Schema, v reflect.Value) (interface{}, error) {
+	if v.IsValid() && v.CanInterface() {
+		if m, ok := v.Interface().(AvroMarshaler); ok && !(v.Kind() == reflect.Ptr && v.IsNil()) {
+			return m.MarshalAvro()
+		}
+		if v.CanAddr() {
+			if m, ok := v.Addr().Interface().(AvroMarshaler); ok {
+				return m.MarshalAvro()
+			}
+		}
+	}
+	if schema.Type == "union" {
+		return unionToNative(schema, v)
+	}
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.Type() != ratType {
+		if v.IsNil() {
+			break
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() || ((v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil()) {
+		if schema.Type == "null" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot encode nil as avro %s", schema.Name)
+	}
+	if schema.LogicalType != "" && (v.Type() == timeType || v.Type() == durationType || v.Type() == ratType) {
+		return v.Interface(), nil
+	}
+	switch schema.Type {
+	case "null":
+		return nil, fmt.Errorf("cannot encode %s as avro null", v.Type())
+	case "boolean":
+		if v.Kind() == reflect.Bool {
+			return v.Bool(), nil
+		}
+	case "int":
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if v.Int() < math.MinInt32 || v.Int() > math.MaxInt32 {
+				return nil, fmt.Errorf("cannot encode %d as avro int, it is out of the int32 range", v.Int())
+			}
+			return int32(v.Int()), nil
+		case reflect.Uint8, reflect.Uint16:
+			return int32(v.Uint()), nil
+		}
+	case "long":
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return v.Int(), nil
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+			return int64(v.Uint()), nil
+		}
+	case "float":
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			return float32(v.Float()), nil
+		}
+	case "double":
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			return v.Float(), nil
+		}
+	case "string", "enum":
+		if v.Kind() == reflect.String {
+			return v.String(), nil
+		}
+	case "bytes", "fixed":
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Bytes(), nil
+		}
+		if v.Kind() == reflect.Array && v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return b, nil
+		}
+	case "array":
+		if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+			items := make([]interface{}, v.Len())
+			for i := range items {
+				item, err := toNative(schema.Items, v.Index(i))
+				if err != nil {
+					return nil, err
+				}
+				items[i] = item
+			}
+			return items, nil
+		}
+	case "map":
+		if v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String {
+			values := make(map[string]interface{}, v.Len())
+			for _, key := range v.MapKeys() {
+				value, err := toNative(schema.Values, v.MapIndex(key))
+				if err != nil {
+					return nil, err
+				}
+				values[key.String()] = value
+			}
+			return values, nil
+		}
+	case "record":
+		if v.Kind() == reflect.Struct {
+			return recordToNative(schema, v)
+		}
+		if v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String {
+			return v.Interface(), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot encode %s as avro %s", v.Type(), schema.Name)
+}
+
+func unionToNative(schema *avroschema.Schema, v reflect.Value) (interface{}, error) {
+	isNil := !v.IsValid() || ((v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface || v.Kind() == reflect.Map || v.Kind() == reflect.Slice) && v.IsNil())
+	for _, branch := range schema.Branches {
+		if branch.Type == "null" {
+			if isNil {
+				return nil, nil
+			}
+			continue
+		}
+		if isNil {
+			continue
+		}
+		native, err := toNative(branch, v)
+		if err == nil {
+			return goavro.Union(branch.Name, native), nil
+		}
+	}
+	if isNil {
+		return nil, fmt.Errorf("cannot encode nil, avro union has no null branch")
+	}
+	return nil, fmt.Errorf("cannot encode %s as any branch of avro union", v.Type())
+}
+
+func recordToNative(schema *avroschema.Schema, v reflect.Value) (interface{}, error) {
+	fields := structFields(v.Type())
+	record := make(map[string]interface{}, len(schema.Fields))
+	for _, field := range schema.Fields {
+		index, ok := fields[field.Name]
+		if !ok {
+			// missing fields are filled with their default by the codec
+			continue
+		}
+		value, err := toNative(field.Type, v.FieldByIndex(index))
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", field.Name, err)
+		}
+		record[field.Name] = value
+	}
+	return record, nil
+}
+
+// structFields maps avro field names to struct fields, using the avro tag when present
+func structFields(t reflect.Type) map[string][]int {
+	fields := make(map[string][]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("avro"); ok {
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		} else if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			for embeddedName, index := range structFields(f.Type) {
+				if _, ok := fields[embeddedName]; !ok {
+					fields[embeddedName] = append([]int{i}, index...)
+				}
+			}
+			continue
+		}
+		fields[name] = []int{i}
+	}
+	return fields
+}
+
+// fromNative sets v from the goavro native form of schema
+func fromNative(schema *avroschema.Schema, native interface{}, v reflect.Value) error {
+	if v.CanAddr() {
+		if u, ok := v.Addr().Interface().(AvroUnmarshaler); ok {
+			return u.UnmarshalAvro(native)
+		}
+	}
+	if schema.Type == "union" {
+		if native == nil {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		union, ok := native.(map[string]interface{})
+		if !ok || len(union) != 1 {
+			return fmt.Errorf("cannot decode %T as avro union", native)
+		}
+		for name, value := range union {
+			for _, branch := range schema.Branches {
+				if branch.Name == name {
+					return fromNative(branch, value, v)
+				}
+			}
+			return fmt.Errorf("unknown avro union branch: %s", name)
+		}
+	}
+	if native == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.Type() == ratType {
+			break
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return fromNative(schema, native, v.Elem())
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(native))
+		return nil
+	}
+	nv := reflect.ValueOf(native)
+	switch schema.Type {
+	case "record":
+		record, ok := native.(map[string]interface{})
+		if !ok {
+			break
+		}
+		if v.Kind() == reflect.Map {
+			v.Set(nv)
+			return nil
+		}
+		if v.Kind() != reflect.Struct {
+			break
+		}
+		fields := structFields(v.Type())
+		for _, field := range schema.Fields {
+			index, ok := fields[field.Name]
+			if !ok {
+				continue
+			}
+			if err := fromNative(field.Type, record[field.Name], v.FieldByIndex(index)); err != nil {
+				return fmt.Errorf("field %s: %v", field.Name, err)
+			}
+		}
+		return nil
+	case "array":
+		items, ok := native.([]interface{})
+		if !ok || v.Kind() != reflect.Slice {
+			break
+		}
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := fromNative(schema.Items, item, slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	case "map":
+		values, ok := native.(map[string]interface{})
+		if !ok || v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+			break
+		}
+		m := reflect.MakeMap(v.Type())
+		for key, value := range values {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := fromNative(schema.Values, value, elem); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+		}
+		v.Set(m)
+		return nil
+	case "bytes", "fixed":
+		if b, ok := native.([]byte); ok && v.Kind() == reflect.Array && v.Type().Elem().Kind() == reflect.Uint8 {
+			reflect.Copy(v, reflect.ValueOf(b))
+			return nil
+		}
+	}
+	switch {
+	case nv.Type().AssignableTo(v.Type()):
+		v.Set(nv)
+		return nil
+	case nv.Type().ConvertibleTo(v.Type()) && isScalar(nv.Kind()) && isScalar(v.Kind()) && (nv.Kind() == reflect.String) == (v.Kind() == reflect.String):
+		if isFloat(nv.Kind()) && !isFloat(v.Kind()) {
+			break
+		}
+		if overflows(nv, v) {
+			return fmt.Errorf("avro %s value %v overflows %s", schema.Name, native, v.Type())
+		}
+		v.Set(nv.Convert(v.Type()))
+		return nil
+	}
+	return fmt.Errorf("cannot decode avro %s into %s", schema.Name, v.Type())
+}
+
+// overflows reports whether the numeric value nv does not fit the kind of v
+func overflows(nv reflect.Value, v reflect.Value) bool {
+	switch nv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return v.OverflowInt(nv.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return nv.Int() < 0 || v.OverflowUint(uint64(nv.Int()))
+		}
+	case reflect.Float32, reflect.Float64:
+		if isFloat(v.Kind()) {
+			return v.OverflowFloat(nv.Float())
+		}
+	}
+	return false
+}
+
+func isFloat(kind reflect.Kind) bool {
+	return kind == reflect.Float32 || kind == reflect.Float64
+}
+
+func isScalar(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package kafka
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/linkedin/goavro"
+)
+
+const testOrderSchema = `{
+	"type": "record",
+	"name": "Order",
+	"namespace": "com.example",
+	"fields": [
+		{"name": "id", "type": {"type": "string", "logicalType": "uuid"}},
+		{"name": "amount", "type": "double"},
+		{"name": "quantity", "type": "int"},
+		{"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["NEW", "PAID"]}},
+		{"name": "created_at", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+		{"name": "note", "type": ["null", "string"], "default": null},
+		{"name": "customer", "type": ["null", {"type": "record", "name": "Customer", "fields": [{"name": "name", "type": "string"}]}], "default": null},
+		{"name": "tags", "type": {"type": "array", "items": "string"}},
+		{"name": "attributes", "type": {"type": "map", "values": "long"}}
+	]
+}`
+
+type testCustomer struct {
+	Name string `avro:"name"`
+}
+
+type testOrder struct {
+	ID         string           `avro:"id"`
+	Amount     float64          `avro:"amount"`
+	Quantity   int              `avro:"quantity"`
+	Status     string           `avro:"status"`
+	CreatedAt  time.Time        `avro:"created_at"`
+	Note       *string          `avro:"note"`
+	Customer   *testCustomer    `avro:"customer"`
+	Tags       []string         `avro:"tags"`
+	Attributes map[string]int64 `avro:"attributes"`
+	Ignored    string           `avro:"-"`
+}
+
+func (o testOrder) AvroSchema() string {
+	return testOrderSchema
+}
+
+func TestMarshalNative_RoundTrip(t *testing.T) {
+	codec, err := goavro.NewCodec(testOrderSchema)
+	if err != nil {
+		t.Fatalf("Could not create codec %v", err)
+	}
+	note := "fragile"
+	order := testOrder{
+		ID:         "1",
+		Amount:     9.5,
+		Quantity:   3,
+		Status:     "PAID",
+		CreatedAt:  time.Unix(1500000000, 0).UTC(),
+		Note:       &note,
+		Customer:   &testCustomer{"jane"},
+		Tags:       []string{"a", "b"},
+		Attributes: map[string]int64{"weight": 2},
+		Ignored:    "ignored",
+	}
+	native, err := MarshalNative(codec, order)
+	if err != nil {
+		t.Fatalf("Error marshaling native: %v", err)
+	}
+	binary, err := codec.BinaryFromNative(nil, native)
+	if err != nil {
+		t.Fatalf("Error encoding native: %v", err)
+	}
+	decoded, _, err := codec.NativeFromBinary(binary)
+	if err != nil {
+		t.Fatalf("Error decoding binary: %v", err)
+	}
+	var result testOrder
+	if err := UnmarshalNative(codec, decoded, &result); err != nil {
+		t.Fatalf("Error unmarshaling native: %v", err)
+	}
+	order.Ignored = ""
+	if !reflect.DeepEqual(order, result) {
+		t.Errorf("Orders do not match. Expected: %+v, got: %+v", order, result)
+	}
+}
+
+func TestMarshalNative_NullUnion(t *testing.T) {
+	codec, err := goavro.NewCodec(testOrderSchema)
+	if err != nil {
+		t.Fatalf("Could not create codec %v", err)
+	}
+	native, err := MarshalNative(codec, &testOrder{ID: "1", Status: "NEW", CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatalf("Error marshaling native: %v", err)
+	}
+	record := native.(map[string]interface{})
+	if record["note"] != nil || record["customer"] != nil {
+		t.Errorf("Expected nil pointers to be encoded as null, got %v and %v", record["note"], record["customer"])
+	}
+	if _, err := codec.BinaryFromNative(nil, native); err != nil {
+		t.Errorf("Error encoding native: %v", err)
+	}
+}
+
+func TestMarshalNative_TypeMismatch(t *testing.T) {
+	codec, err := goavro.NewCodec(`{"type": "record", "name": "test", "fields": [{"name": "val", "type": "int"}]}`)
+	if err != nil {
+		t.Fatalf("Could not create codec %v", err)
+	}
+	if _, err := MarshalNative(codec, struct{ Val string }{"x"}); err != nil {
+		t.Errorf("Expected unmatched field to be left to the codec, got %v", err)
+	}
+	if _, err := MarshalNative(codec, struct {
+		Val string `avro:"val"`
+	}{"x"}); err == nil {
+		t.Errorf("Expected error encoding a string as int")
+	}
+}
+
+func TestMarshalNative_IntRange(t *testing.T) {
+	codec, err := goavro.NewCodec(`{"type": "record", "name": "test", "fields": [{"name": "val", "type": "int"}]}`)
+	if err != nil {
+		t.Fatalf("Could not create codec %v", err)
+	}
+	type record struct {
+		Val int64 `avro:"val"`
+	}
+	if _, err := MarshalNative(codec, record{1<<32 + 5}); err == nil {
+		t.Errorf("Expected error encoding a value out of the int32 range as int")
+	}
+	if _, err := MarshalNative(codec, record{-1 << 31}); err != nil {
+		t.Errorf("Error encoding the smallest int32: %v", err)
+	}
+}
+
+func TestUnmarshalNative_Conversions(t *testing.T) {
+	codec, err := goavro.NewCodec(`{"type": "record", "name": "test", "fields": [
+		{"name": "d", "type": "double"},
+		{"name": "l", "type": "long"},
+		{"name": "m", "type": {"type": "map", "values": "int"}}
+	]}`)
+	if err != nil {
+		t.Fatalf("Could not create codec %v", err)
+	}
+	native := map[string]interface{}{"d": 3.9, "l": int64(1 << 40), "m": map[string]interface{}{"a": int32(1)}}
+	var truncated struct {
+		D int `avro:"d"`
+	}
+	if err := UnmarshalNative(codec, native, &truncated); err == nil {
+		t.Errorf("Expected error decoding a double into an int, got %d", truncated.D)
+	}
+	var overflowed struct {
+		L int8 `avro:"l"`
+	}
+	if err := UnmarshalNative(codec, native, &overflowed); err == nil {
+		t.Errorf("Expected error decoding a long out of the int8 range, got %d", overflowed.L)
+	}
+	var widened struct {
+		D float32          `avro:"d"`
+		L int64            `avro:"l"`
+		M map[string]int64 `avro:"m"`
+	}
+	if err := UnmarshalNative(codec, native, &widened); err != nil || widened.L != 1<<40 || widened.M["a"] != 1 {
+		t.Errorf("Expected lossless conversions to decode, got %+v, %v", widened, err)
+	}
+	var intKeys struct {
+		M map[int]int `avro:"m"`
+	}
+	if err := UnmarshalNative(codec, native, &intKeys); err == nil {
+		t.Errorf("Expected error decoding an avro map into a map without string keys")
+	}
+}
@@ -1,6 +1,7 @@
 package kafka
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/binary"
 
@@ -12,74 +13,245 @@ type AvroProducerConfig struct {
 	KafkaServers          []string
 	SchemaRegistryServers []string
 	SASL                  *SASLConfig
+	// TLS enables TLS on the kafka connection, independently of SASL
+	TLS *tls.Config
+	// SchemaRegistryTLS is the TLS config of the schema registry connection, certificates are verified
+	// against the system roots when nil
+	SchemaRegistryTLS *tls.Config
+	// SchemaRegistryAuth authenticates the schema registry requests, the SASL credentials are used when nil
+	SchemaRegistryAuth SchemaRegistryAuth
+	// SchemaRegistryOptions configure the schema registry client further, e.g. its http client and timeout
+	SchemaRegistryOptions []SchemaRegistryOption
+	// SubjectNameStrategy maps topics to subjects, TopicNameStrategy is used when nil
+	SubjectNameStrategy SubjectNameStrategy
+	// SchemaRegistryClient replaces the cached client created from SchemaRegistryServers and the other
+	// schema registry settings, e.g. with a MockSchemaRegistryClient in tests
+	SchemaRegistryClient SchemaRegistryClientInterface
 }
 
 type AvroProducer struct {
 	producer             sarama.SyncProducer
-	schemaRegistryClient *CachedSchemaRegistryClient
+	schemaRegistryClient SchemaRegistryClientInterface
 	SASL                 *SASLConfig
 }
 
-type SASLConfig struct {
-	Username  string
-	Password  string
-	TLSConfig *tls.Config
+// MessageKey is the key of a kafka message, when Schema is empty Value is sent as is,
+// otherwise Value is textual avro data encoded against Schema
+type MessageKey struct {
+	Schema string
+	Value  []byte
+}
+
+// BytesKey returns a key sent as raw bytes
+func BytesKey(key []byte) MessageKey {
+	return MessageKey{Value: key}
+}
+
+// StringKey returns a key sent as a raw string
+func StringKey(key string) MessageKey {
+	return MessageKey{Value: []byte(key)}
+}
+
+// AvroKey returns a key encoded with its own schema, registered under the <topic>-key subject by default
+func AvroKey(schema string, key []byte) MessageKey {
+	return MessageKey{Schema: schema, Value: key}
 }
 
 // NewAvroProducer is a basic producer to interact with schema registry, avro and kafka
 func NewAvroProducer(cfg AvroProducerConfig) (*AvroProducer, error) {
+	config := newProducerConfig(cfg)
+	producer, err := sarama.NewSyncProducer(cfg.KafkaServers, config)
+	if err != nil {
+		return nil, err
+	}
+	return &AvroProducer{producer, cfg.schemaRegistryClient(), cfg.SASL}, nil
+}
+
+// schemaRegistryClient returns SchemaRegistryClient or a cached client created from the schema registry settings
+func (cfg AvroProducerConfig) schemaRegistryClient() SchemaRegistryClientInterface {
+	return schemaRegistrySettings{
+		servers:  cfg.SchemaRegistryServers,
+		sasl:     cfg.SASL,
+		tls:      cfg.SchemaRegistryTLS,
+		auth:     cfg.SchemaRegistryAuth,
+		options:  cfg.SchemaRegistryOptions,
+		strategy: cfg.SubjectNameStrategy,
+		client:   cfg.SchemaRegistryClient,
+	}.newClient()
+}
+
+// newProducerConfig returns the sarama config shared by the sync and async producers
+func newProducerConfig(cfg AvroProducerConfig) *sarama.Config {
 	config := sarama.NewConfig()
-	config.Producer.Partitioner = sarama.NewRandomPartitioner
+	// messages with a key always land on the same partition, the others are spread randomly
+	config.Producer.Partitioner = sarama.NewHashPartitioner
 	config.Producer.Return.Successes = true
 	config.Producer.RequiredAcks = sarama.WaitForAll
+	setBrokerSecurity(config, cfg.SASL, cfg.TLS)
+	return config
+}
+
+// GetSchemaId get schema id from schema-registry service
+func (ap *AvroProducer) GetSchemaId(topic string, avroCodec *goavro.Codec) (int, error) {
+	return getSchemaId(context.Background(), ap.schemaRegistryClient, topic, false, avroCodec)
+}
+
+// GetKeySchemaId get key schema id from schema-registry service
+func (ap *AvroProducer) GetKeySchemaId(topic string, avroCodec *goavro.Codec) (int, error) {
+	return getSchemaId(context.Background(), ap.schemaRegistryClient, topic, true, avroCodec)
+}
+
+// Add sends an avro encoded value without a key, the partition is picked randomly
+func (ap *AvroProducer) Add(topic string, schema string, value []byte) error {
+	return ap.AddContext(context.Background(), topic, schema, value)
+}
+
+// AddContext is Add with a context bounding the schema registry requests
+func (ap *AvroProducer) AddContext(ctx context.Context, topic string, schema string, value []byte) error {
+	return ap.AddWithKeyContext(ctx, topic, MessageKey{}, schema, value)
+}
+
+// AddWithKey sends an avro encoded value with the given key, messages with the same key land on the same partition
+func (ap *AvroProducer) AddWithKey(topic string, key MessageKey, schema string, value []byte) error {
+	return ap.AddWithKeyContext(context.Background(), topic, key, schema, value)
+}
 
-	if cfg.SASL != nil {
-		config.Net.SASL.Enable = true
-		config.Net.SASL.User = cfg.SASL.Username
-		config.Net.SASL.Password = cfg.SASL.Password
-		config.Net.SASL.Handshake = true
-		config.Net.SASL.Mechanism = sarama.SASLTypePlaintext
-		config.Net.TLS.Enable = true
-		config.Net.TLS.Config = cfg.SASL.TLSConfig
+// AddWithKeyContext is AddWithKey with a context bounding the schema registry requests
+func (ap *AvroProducer) AddWithKeyContext(ctx context.Context, topic string, key MessageKey, schema string, value []byte) error {
+	msg, err := newAvroMessage(ctx, ap.schemaRegistryClient, topic, key, schema, value)
+	if err != nil {
+		return err
 	}
-	producer, err := sarama.NewSyncProducer(cfg.KafkaServers, config)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	_, _, err = ap.producer.SendMessage(msg)
+	return err
+}
+
+// Produce sends v, a Go value converted with MarshalNative, without a key. The schema is taken from
+// AvroSchema() when v implements AvroSchemaProvider, otherwise the latest schema of the value subject is used
+func (ap *AvroProducer) Produce(ctx context.Context, topic string, v interface{}) error {
+	return ap.ProduceWithKey(ctx, topic, MessageKey{}, v)
+}
+
+// ProduceWithKey sends v, a Go value converted with MarshalNative, with the given key
+func (ap *AvroProducer) ProduceWithKey(ctx context.Context, topic string, key MessageKey, v interface{}) error {
+	avroCodec, err := valueCodec(ctx, ap.schemaRegistryClient, topic, v)
+	if err != nil {
+		return err
+	}
+	native, err := MarshalNative(avroCodec, v)
+	if err != nil {
+		return err
+	}
+	msg, err := newNativeMessage(ctx, ap.schemaRegistryClient, topic, key, avroCodec, native)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	_, _, err = ap.producer.SendMessage(msg)
+	return err
+}
+
+// valueCodec returns the codec of v, or of the latest schema registered for the topic values
+func valueCodec(ctx context.Context, client SchemaRegistryClientInterface, topic string, v interface{}) (*goavro.Codec, error) {
+	if provider, ok := v.(AvroSchemaProvider); ok {
+		return goavro.NewCodec(provider.AvroSchema())
+	}
+	subject, err := subjectName(client, topic, false, nil)
 	if err != nil {
 		return nil, err
 	}
-	schemaRegistryClient := NewCachedSchemaRegistryClient(cfg.SchemaRegistryServers, cfg.SASL)
-	return &AvroProducer{producer, schemaRegistryClient, cfg.SASL}, nil
+	return client.GetLatestSchemaContext(ctx, subject)
 }
 
-// GetSchemaId get schema id from schema-registry service
-func (ap *AvroProducer) GetSchemaId(topic string, avroCodec *goavro.Codec) (int, error) {
-	schemaId, err := ap.schemaRegistryClient.CreateSubject(topic, avroCodec)
+func getSchemaId(ctx context.Context, client SchemaRegistryClientInterface, topic string, isKey bool, avroCodec *goavro.Codec) (int, error) {
+	subject, err := subjectName(client, topic, isKey, avroCodec)
+	if err != nil {
+		return 0, err
+	}
+	schemaId, err := client.CreateSubjectContext(ctx, subject, avroCodec)
 	if err != nil {
 		return 0, err
 	}
 	return schemaId, nil
 }
 
-func (ap *AvroProducer) Add(topic string, schema string, value []byte) error {
+// newAvroMessage registers the schemas and builds a message in the confluent wire format
+func newAvroMessage(ctx context.Context, client SchemaRegistryClientInterface, topic string, key MessageKey, schema string, value []byte) (*sarama.ProducerMessage, error) {
 	avroCodec, err := goavro.NewCodec(schema)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	schemaId, err := ap.GetSchemaId(topic, avroCodec)
+	native, _, err := avroCodec.NativeFromTextual(value)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	binarySchemaId := make([]byte, 4)
-	binary.BigEndian.PutUint32(binarySchemaId, uint32(schemaId))
+	return newNativeMessage(ctx, client, topic, key, avroCodec, native)
+}
 
-	native, _, err := avroCodec.NativeFromTextual(value)
+// newNativeMessage registers the schemas and builds a message in the confluent wire format from native Go form
+func newNativeMessage(ctx context.Context, client SchemaRegistryClientInterface, topic string, key MessageKey, avroCodec *goavro.Codec, native interface{}) (*sarama.ProducerMessage, error) {
+	schemaId, err := getSchemaId(ctx, client, topic, false, avroCodec)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	binaryMsg, err := encodeNative(avroCodec, schemaId, native)
+	if err != nil {
+		return nil, err
+	}
+	binaryKey, err := encodeKey(ctx, client, topic, key)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &sarama.ProducerMessage{
+		Topic: topic,
+		Value: sarama.ByteEncoder(binaryMsg),
 	}
+	if binaryKey != nil {
+		msg.Key = sarama.ByteEncoder(binaryKey)
+	}
+	return msg, nil
+}
+
+// encodeKey returns the raw key bytes, or the framed avro key registered under its key subject
+func encodeKey(ctx context.Context, client SchemaRegistryClientInterface, topic string, key MessageKey) ([]byte, error) {
+	if key.Schema == "" {
+		return key.Value, nil
+	}
+	keyCodec, err := goavro.NewCodec(key.Schema)
+	if err != nil {
+		return nil, err
+	}
+	keySchemaId, err := getSchemaId(ctx, client, topic, true, keyCodec)
+	if err != nil {
+		return nil, err
+	}
+	return encodeAvro(keyCodec, keySchemaId, key.Value)
+}
+
+// encodeAvro converts textual avro data to the confluent wire format
+func encodeAvro(codec *goavro.Codec, schemaId int, textual []byte) ([]byte, error) {
+	native, _, err := codec.NativeFromTextual(textual)
+	if err != nil {
+		return nil, err
+	}
+	return encodeNative(codec, schemaId, native)
+}
+
+// encodeNative converts native Go form to the confluent wire format
+func encodeNative(codec *goavro.Codec, schemaId int, native interface{}) ([]byte, error) {
+	binarySchemaId := make([]byte, 4)
+	binary.BigEndian.PutUint32(binarySchemaId, uint32(schemaId))
 
 	// Convert native Go form to binary Avro data
-	binaryValue, err := avroCodec.BinaryFromNative(nil, native)
+	binaryValue, err := codec.BinaryFromNative(nil, native)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var binaryMsg []byte
@@ -89,13 +261,7 @@ func (ap *AvroProducer) Add(topic string, schema string, value []byte) error {
 	binaryMsg = append(binaryMsg, binarySchemaId...)
 	//avro serialized data in Avro’s binary encoding
 	binaryMsg = append(binaryMsg, binaryValue...)
-
-	msg := &sarama.ProducerMessage{
-		Topic: topic,
-		Value: sarama.StringEncoder(binaryMsg),
-	}
-	_, _, err = ap.producer.SendMessage(msg)
-	return err
+	return binaryMsg, nil
 }
 
 func (ac *AvroProducer) Close() {
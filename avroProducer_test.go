@@ -1,8 +1,12 @@
 package kafka
 
 import (
+	"context"
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/Shopify/sarama"
 	"github.com/Shopify/sarama/mocks"
 )
 
@@ -21,4 +25,125 @@ func TestAvroProducer_Add(t *testing.T) {
 	if nil != err {
 		t.Errorf("Error adding msg: %v", err)
 	}
+	if !containsStr(schemaRegistryTestObject.Registered, "test-value") {
+		t.Errorf("Expected schema to be registered under test-value, got %v", schemaRegistryTestObject.Registered)
+	}
+}
+
+func TestAvroProducer_AddWithKey(t *testing.T) {
+	producerMock := mocks.NewSyncProducer(t, nil)
+	producerMock.ExpectSendMessageAndSucceed()
+	producerMock.ExpectSendMessageAndSucceed()
+	saslConfig := &SASLConfig{
+		Username: "test",
+	}
+	schemaRegistryTestObject := createSchemaRegistryTestObject(t, "test", 1)
+	schemaRegistryMock := NewCachedSchemaRegistryClient([]string{schemaRegistryTestObject.MockServer.URL}, saslConfig)
+
+	avroProducer := &AvroProducer{producerMock, schemaRegistryMock, saslConfig}
+	defer avroProducer.Close()
+	schema := schemaRegistryTestObject.Codec.Schema()
+	err := avroProducer.AddWithKey("test", StringKey("key"), schema, []byte(`{"val":1}`))
+	if nil != err {
+		t.Errorf("Error adding msg with string key: %v", err)
+	}
+	err = avroProducer.AddWithKey("test", AvroKey(`"string"`, []byte(`"key"`)), schema, []byte(`{"val":1}`))
+	if nil != err {
+		t.Errorf("Error adding msg with avro key: %v", err)
+	}
+	if !containsStr(schemaRegistryTestObject.Registered, "test-key") {
+		t.Errorf("Expected key schema to be registered under test-key, got %v", schemaRegistryTestObject.Registered)
+	}
+}
+
+func TestAvroProducer_SubjectNameStrategy(t *testing.T) {
+	producerMock := mocks.NewSyncProducer(t, nil)
+	producerMock.ExpectSendMessageAndSucceed()
+	schemaRegistryTestObject := createSchemaRegistryTestObject(t, "test", 1)
+	schemaRegistryMock := NewCachedSchemaRegistryClient([]string{schemaRegistryTestObject.MockServer.URL}, nil)
+	schemaRegistryMock.SchemaRegistryClient.SubjectNameStrategy = TopicRecordNameStrategy
+
+	avroProducer := &AvroProducer{producerMock, schemaRegistryMock, nil}
+	defer avroProducer.Close()
+	err := avroProducer.Add("events", schemaRegistryTestObject.Codec.Schema(), []byte(`{"val":1}`))
+	if nil != err {
+		t.Errorf("Error adding msg: %v", err)
+	}
+	if !reflect.DeepEqual(schemaRegistryTestObject.Registered, []string{"events-test"}) {
+		t.Errorf("Expected schema to be registered under events-test, got %v", schemaRegistryTestObject.Registered)
+	}
+}
+
+func TestAvroProducer_Produce(t *testing.T) {
+	producerMock := mocks.NewSyncProducer(t, nil)
+	producerMock.ExpectSendMessageAndSucceed()
+	producerMock.ExpectSendMessageAndSucceed()
+	schemaRegistryTestObject := createSchemaRegistryTestObject(t, "test-value", 1)
+	schemaRegistryMock := NewCachedSchemaRegistryClient([]string{schemaRegistryTestObject.MockServer.URL}, nil)
+
+	avroProducer := &AvroProducer{producerMock, schemaRegistryMock, nil}
+	defer avroProducer.Close()
+	err := avroProducer.Produce(context.Background(), "test", testOrder{ID: "1", Status: "NEW", CreatedAt: time.Now()})
+	if nil != err {
+		t.Errorf("Error producing struct with schema: %v", err)
+	}
+	// without AvroSchema the latest schema of the subject is used
+	err = avroProducer.Produce(context.Background(), "test", struct {
+		Val int `avro:"val"`
+	}{1})
+	if nil != err {
+		t.Errorf("Error producing struct with latest schema: %v", err)
+	}
+}
+
+func TestAvroProducer_SchemaRegistryClient(t *testing.T) {
+	schema := `{"type": "record", "name": "test", "fields" : [{"name": "val", "type": "int", "default": 0}]}`
+	var sent []byte
+	producerMock := mocks.NewSyncProducer(t, nil)
+	producerMock.ExpectSendMessageWithCheckerFunctionAndSucceed(func(value []byte) error {
+		sent = value
+		return nil
+	})
+	registry := NewMockSchemaRegistryClient()
+	cfg := AvroProducerConfig{SchemaRegistryClient: registry, SubjectNameStrategy: TopicRecordNameStrategy}
+
+	avroProducer := &AvroProducer{producerMock, cfg.schemaRegistryClient(), nil}
+	defer avroProducer.Close()
+	err := avroProducer.Add("events", schema, []byte(testData))
+	if nil != err {
+		t.Errorf("Error adding msg: %v", err)
+	}
+	if versions, err := registry.GetVersions("events-test"); err != nil || len(versions) != 1 {
+		t.Errorf("Expected schema to be registered under events-test, got %v, %v", versions, err)
+	}
+
+	avroConsumer := &avroConsumer{nil, registry, ConsumerCallbacks{}, nil, FailurePolicy{}, nil}
+	msg, err := avroConsumer.ProcessAvroMsg(&sarama.ConsumerMessage{Topic: "events", Value: sent})
+	if err != nil {
+		t.Errorf("Error process avro msg: %v", err)
+	}
+	if msg.Value != testData {
+		t.Errorf("Wrong data, got %s", msg.Value)
+	}
+}
+
+func TestAvroProducer_AddContext(t *testing.T) {
+	producerMock := mocks.NewSyncProducer(t, nil)
+	producerMock.ExpectSendMessageAndSucceed()
+	registry := NewMockSchemaRegistryClient()
+	avroProducer := &AvroProducer{producerMock, registry, nil}
+	defer avroProducer.Close()
+	schema := `{"type": "record", "name": "test", "fields" : [{"name": "val", "type": "int", "default": 0}]}`
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := avroProducer.AddContext(ctx, "test", schema, []byte(testData)); err != context.Canceled {
+		t.Errorf("Expected the cancelled context error, got %v", err)
+	}
+	if subjects, _ := registry.GetSubjects(); len(subjects) != 0 {
+		t.Errorf("Expected no schema to be registered, got %v", subjects)
+	}
+	if err := avroProducer.AddWithKeyContext(context.Background(), "test", StringKey("key"), schema, []byte(testData)); err != nil {
+		t.Errorf("Error adding msg: %v", err)
+	}
 }
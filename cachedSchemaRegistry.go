@@ -1,7 +1,11 @@
 package kafka
 
 import (
-	"sync"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
 
 	"github.com/linkedin/goavro"
 )
@@ -9,39 +13,131 @@ import (
 // CachedSchemaRegistryClient is a schema registry client that will cache some data to improve performance
 type CachedSchemaRegistryClient struct {
 	SchemaRegistryClient *SchemaRegistryClient
-	schemaCache          map[int]*goavro.Codec
-	schemaCacheLock      sync.RWMutex
-	schemaIdCache        map[string]int
-	schemaIdCacheLock    sync.RWMutex
-	SASL                 *SASLConfig
+	cacheConfig          CacheConfig
+	// schemaCache holds the codecs by id
+	schemaCache *lruCache
+	// schemaIdCache holds the ids of registered schemas by subject and compacted schema
+	schemaIdCache *lruCache
+	// subjectCache holds the schemas by subject version, the latest schemas and the versions of subjects
+	subjectCache *lruCache
+	// flights collapses concurrent cache misses for the same schema into one registry call
+	flights *flightGroup
+	// store persists the schemas, nil unless WithSchemaStore is used
+	store SchemaStore
+	// storeErrors is called with the errors of store, nil unless WithSchemaStoreErrors is used
+	storeErrors func(err error)
+	// stored indexes the schemas of store in memory for the fallbacks when the registry fails
+	stored *schemaIndex
+	SASL   *SASLConfig
 }
 
+// CacheStats are counters of a CachedSchemaRegistryClient
+type CacheStats struct {
+	// CoalescedCalls is the number of GetSchema and CreateSubject calls that shared the result of a registry call in flight
+	CoalescedCalls int64
+}
+
+type subjectVersionKey struct {
+	subject string
+	version int
+}
+
+// subjectSchemaKey identifies a schema registered to a subject, schema is the compacted json of the schema
+// so schemas differing only in whitespace share an entry
+type subjectSchemaKey struct {
+	subject string
+	schema  string
+}
+
+// compactSchema returns the schema of codec without insignificant whitespace. Unlike the Parsing Canonical Form
+// it keeps defaults, docs and aliases, which make a different version of a schema for the registry.
+func compactSchema(codec *goavro.Codec) string {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, []byte(codec.Schema())); err != nil {
+		return codec.Schema()
+	}
+	return compact.String()
+}
+
+type latestSchemaKey string
+
+type subjectVersionsKey string
+
 func NewCachedSchemaRegistryClient(connect []string, saslConfig *SASLConfig) *CachedSchemaRegistryClient {
-	SchemaRegistryClient := NewSchemaRegistryClient(connect, saslConfig)
-	return &CachedSchemaRegistryClient{SchemaRegistryClient: SchemaRegistryClient, schemaCache: make(map[int]*goavro.Codec), schemaIdCache: make(map[string]int)}
+	return NewCachedSchemaRegistryClientWithOptions(connect, WithSASL(saslConfig))
+}
+
+// NewCachedSchemaRegistryClientWithOptions creates a cached client, see NewSchemaRegistryClientWithOptions.
+// WithCacheConfig bounds its caches.
+func NewCachedSchemaRegistryClientWithOptions(connect []string, opts ...SchemaRegistryOption) *CachedSchemaRegistryClient {
+	options := newSchemaRegistryOptions(connect, opts)
+	cacheConfig := options.cacheConfig
+	client := &CachedSchemaRegistryClient{
+		SchemaRegistryClient: options.client(connect),
+		cacheConfig:          cacheConfig,
+		schemaCache:          newLRUCache(cacheConfig.MaxEntries),
+		schemaIdCache:        newLRUCache(cacheConfig.MaxEntries),
+		subjectCache:         newLRUCache(cacheConfig.MaxEntries),
+		flights:              newFlightGroup(),
+		store:                options.schemaStore,
+		storeErrors:          options.schemaStoreErrors,
+		SASL:                 options.sasl,
+	}
+	client.warm()
+	return client
+}
+
+// NewCachedSchemaRegistryClientWithTLS creates a cached client connecting with tlsConfig, see NewSchemaRegistryClientWithTLS
+func NewCachedSchemaRegistryClientWithTLS(connect []string, tlsConfig *tls.Config, saslConfig *SASLConfig) *CachedSchemaRegistryClient {
+	return NewCachedSchemaRegistryClientWithOptions(connect, WithTLSConfig(tlsConfig), WithSASL(saslConfig))
 }
 
 func NewCachedSchemaRegistryClientWithRetries(connect []string, retries int, saslConfig *SASLConfig) *CachedSchemaRegistryClient {
-	SchemaRegistryClient := NewSchemaRegistryClientWithRetries(connect, retries, saslConfig)
-	return &CachedSchemaRegistryClient{SchemaRegistryClient: SchemaRegistryClient, schemaCache: make(map[int]*goavro.Codec), schemaIdCache: make(map[string]int)}
+	return NewCachedSchemaRegistryClientWithOptions(connect, WithRetries(retries), WithSASL(saslConfig))
+}
+
+// EndpointStatus returns the health of every server of the schema registry
+func (client *CachedSchemaRegistryClient) EndpointStatus() []EndpointStatus {
+	return client.SchemaRegistryClient.EndpointStatus()
+}
+
+// Stats returns the counters of the client
+func (client *CachedSchemaRegistryClient) Stats() CacheStats {
+	return CacheStats{CoalescedCalls: client.flights.coalescedCalls()}
+}
+
+// SubjectName returns the subject for the key or value schema of a topic using the configured SubjectNameStrategy
+func (client *CachedSchemaRegistryClient) SubjectName(topic string, isKey bool, codec *goavro.Codec) (string, error) {
+	return client.SchemaRegistryClient.SubjectName(topic, isKey, codec)
 }
 
 // GetSchema will return and cache the codec with the given id
 func (client *CachedSchemaRegistryClient) GetSchema(id int) (*goavro.Codec, error) {
-	client.schemaCacheLock.RLock()
-	cachedResult := client.schemaCache[id]
-	client.schemaCacheLock.RUnlock()
-	if nil != cachedResult {
-		return cachedResult, nil
+	return client.GetSchemaContext(context.Background(), id)
+}
+
+// GetSchemaContext will return and cache the codec with the given id
+func (client *CachedSchemaRegistryClient) GetSchemaContext(ctx context.Context, id int) (*goavro.Codec, error) {
+	if cachedResult, found := client.schemaCache.get(id); found {
+		return cachedResult.(*goavro.Codec), nil
 	}
-	codec, err := client.SchemaRegistryClient.GetSchema(id)
+	codec, err := client.flights.do(ctx, id, func(ctx context.Context) (interface{}, error) {
+		codec, err := client.SchemaRegistryClient.GetSchemaContext(ctx, id)
+		if err != nil {
+			if stored := client.storedSchema(id); stored != nil {
+				client.schemaCache.set(id, stored, 0)
+				return stored, nil
+			}
+			return nil, err
+		}
+		client.schemaCache.set(id, codec, 0)
+		client.save(id, "", codec)
+		return codec, nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	client.schemaCacheLock.Lock()
-	client.schemaCache[id] = codec
-	client.schemaCacheLock.Unlock()
-	return codec, nil
+	return codec.(*goavro.Codec), nil
 }
 
 // GetSubjects returns a list of subjects
@@ -49,38 +145,98 @@ func (client *CachedSchemaRegistryClient) GetSubjects() ([]string, error) {
 	return client.SchemaRegistryClient.GetSubjects()
 }
 
-// GetVersions returns a list of all versions of a subject
+// GetSubjectsContext returns a list of subjects
+func (client *CachedSchemaRegistryClient) GetSubjectsContext(ctx context.Context) ([]string, error) {
+	return client.SchemaRegistryClient.GetSubjectsContext(ctx)
+}
+
+// GetVersions returns and caches for the cache TTL a list of all versions of a subject
 func (client *CachedSchemaRegistryClient) GetVersions(subject string) ([]int, error) {
-	return client.SchemaRegistryClient.GetVersions(subject)
+	return client.GetVersionsContext(context.Background(), subject)
 }
 
-// GetSchemaByVersion returns the codec for a specific version of a subject
+// GetVersionsContext returns and caches for the cache TTL a list of all versions of a subject
+func (client *CachedSchemaRegistryClient) GetVersionsContext(ctx context.Context, subject string) ([]int, error) {
+	if cachedResult, found := client.subjectCache.get(subjectVersionsKey(subject)); found {
+		return append([]int{}, cachedResult.([]int)...), nil
+	}
+	versions, err := client.SchemaRegistryClient.GetVersionsContext(ctx, subject)
+	if err != nil {
+		return versions, err
+	}
+	if client.cacheConfig.TTL > 0 {
+		client.subjectCache.set(subjectVersionsKey(subject), append([]int{}, versions...), client.cacheConfig.TTL)
+	}
+	return versions, nil
+}
+
+// GetSchemaByVersion returns and caches the codec for a specific version of a subject
 func (client *CachedSchemaRegistryClient) GetSchemaByVersion(subject string, version int) (*goavro.Codec, error) {
-	return client.SchemaRegistryClient.GetSchemaByVersion(subject, version)
+	return client.GetSchemaByVersionContext(context.Background(), subject, version)
+}
+
+// GetSchemaByVersionContext returns and caches the codec for a specific version of a subject
+func (client *CachedSchemaRegistryClient) GetSchemaByVersionContext(ctx context.Context, subject string, version int) (*goavro.Codec, error) {
+	key := subjectVersionKey{subject, version}
+	if cachedResult, found := client.subjectCache.get(key); found {
+		return cachedResult.(*goavro.Codec), nil
+	}
+	codec, err := client.SchemaRegistryClient.GetSchemaByVersionContext(ctx, subject, version)
+	if err != nil {
+		return nil, err
+	}
+	client.subjectCache.set(key, codec, 0)
+	return codec, nil
 }
 
-// GetLatestSchema returns the highest version schema for a subject
+// GetLatestSchema returns and caches for the cache TTL the highest version schema for a subject
 func (client *CachedSchemaRegistryClient) GetLatestSchema(subject string) (*goavro.Codec, error) {
-	return client.SchemaRegistryClient.GetLatestSchema(subject)
+	return client.GetLatestSchemaContext(context.Background(), subject)
+}
+
+// GetLatestSchemaContext returns and caches for the cache TTL the highest version schema for a subject
+func (client *CachedSchemaRegistryClient) GetLatestSchemaContext(ctx context.Context, subject string) (*goavro.Codec, error) {
+	if cachedResult, found := client.subjectCache.get(latestSchemaKey(subject)); found {
+		return cachedResult.(*goavro.Codec), nil
+	}
+	codec, err := client.SchemaRegistryClient.GetLatestSchemaContext(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+	if client.cacheConfig.TTL > 0 {
+		client.subjectCache.set(latestSchemaKey(subject), codec, client.cacheConfig.TTL)
+	}
+	return codec, nil
 }
 
 // CreateSubject will return and cache the id with the given codec
 func (client *CachedSchemaRegistryClient) CreateSubject(subject string, codec *goavro.Codec) (int, error) {
-	schemaJson := codec.Schema()
-	client.schemaIdCacheLock.RLock()
-	cachedResult, found := client.schemaIdCache[schemaJson]
-	client.schemaIdCacheLock.RUnlock()
-	if found {
-		return cachedResult, nil
-	}
-	id, err := client.SchemaRegistryClient.CreateSubject(subject, codec)
+	return client.CreateSubjectContext(context.Background(), subject, codec)
+}
+
+// CreateSubjectContext will return and cache the id with the given codec for the subject
+func (client *CachedSchemaRegistryClient) CreateSubjectContext(ctx context.Context, subject string, codec *goavro.Codec) (int, error) {
+	key := subjectSchemaKey{subject, compactSchema(codec)}
+	if cachedResult, found := client.schemaIdCache.get(key); found {
+		return cachedResult.(int), nil
+	}
+	id, err := client.flights.do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		id, err := client.SchemaRegistryClient.CreateSubjectContext(ctx, subject, codec)
+		if err != nil {
+			if stored, ok := client.storedId(key); ok {
+				client.schemaIdCache.set(key, stored, 0)
+				return stored, nil
+			}
+			return nil, err
+		}
+		client.schemaIdCache.set(key, id, 0)
+		client.save(id, subject, codec)
+		return id, nil
+	})
 	if err != nil {
 		return 0, err
 	}
-	client.schemaIdCacheLock.Lock()
-	client.schemaIdCache[schemaJson] = id
-	client.schemaIdCacheLock.Unlock()
-	return id, nil
+	return id.(int), nil
 }
 
 // IsSchemaRegistered checks if a specific codec is already registered to a subject
@@ -88,12 +244,112 @@ func (client *CachedSchemaRegistryClient) IsSchemaRegistered(subject string, cod
 	return client.SchemaRegistryClient.IsSchemaRegistered(subject, codec)
 }
 
+// IsSchemaRegisteredContext checks if a specific codec is already registered to a subject
+func (client *CachedSchemaRegistryClient) IsSchemaRegisteredContext(ctx context.Context, subject string, codec *goavro.Codec) (int, error) {
+	return client.SchemaRegistryClient.IsSchemaRegisteredContext(ctx, subject, codec)
+}
+
 // DeleteSubject deletes the subject, should only be used in development
 func (client *CachedSchemaRegistryClient) DeleteSubject(subject string) error {
-	return client.SchemaRegistryClient.DeleteSubject(subject)
+	return client.DeleteSubjectContext(context.Background(), subject)
+}
+
+// DeleteSubjectContext deletes the subject and its cached versions, should only be used in development
+func (client *CachedSchemaRegistryClient) DeleteSubjectContext(ctx context.Context, subject string) error {
+	err := client.SchemaRegistryClient.DeleteSubjectContext(ctx, subject)
+	client.removeSchemaIds(subject)
+	client.subjectCache.removeIf(func(key interface{}) bool {
+		switch key := key.(type) {
+		case subjectVersionKey:
+			return key.subject == subject
+		case latestSchemaKey:
+			return string(key) == subject
+		case subjectVersionsKey:
+			return string(key) == subject
+		}
+		return false
+	})
+	return err
 }
 
 // DeleteVersion deletes the a specific version of a subject, should only be used in development.
 func (client *CachedSchemaRegistryClient) DeleteVersion(subject string, version int) error {
-	return client.SchemaRegistryClient.DeleteVersion(subject, version)
+	return client.DeleteVersionContext(context.Background(), subject, version)
+}
+
+// DeleteVersionContext deletes the a specific version of a subject, should only be used in development.
+func (client *CachedSchemaRegistryClient) DeleteVersionContext(ctx context.Context, subject string, version int) error {
+	err := client.SchemaRegistryClient.DeleteVersionContext(ctx, subject, version)
+	client.removeSchemaIds(subject)
+	client.subjectCache.removeIf(func(key interface{}) bool {
+		return key == subjectVersionKey{subject, version} || key == latestSchemaKey(subject) || key == subjectVersionsKey(subject)
+	})
+	return err
+}
+
+// removeSchemaIds drops the cached ids of the schemas registered to subject
+func (client *CachedSchemaRegistryClient) removeSchemaIds(subject string) {
+	client.schemaIdCache.removeIf(func(key interface{}) bool {
+		return key.(subjectSchemaKey).subject == subject
+	})
+}
+
+// warm fills the caches and the index of stored schemas with the store, stored schemas that are not valid are
+// reported and ignored
+func (client *CachedSchemaRegistryClient) warm() {
+	if client.store == nil {
+		return
+	}
+	client.stored = newSchemaIndex()
+	schemas, err := client.store.Load()
+	if err != nil {
+		client.storeError(fmt.Errorf("could not load the stored schemas: %v", err))
+		return
+	}
+	for _, stored := range schemas {
+		codec, err := goavro.NewCodec(stored.Schema)
+		if err != nil {
+			client.storeError(fmt.Errorf("stored schema %d is not valid: %v", stored.Id, err))
+			continue
+		}
+		client.stored.add(stored.Id, stored.Subject, codec)
+		client.schemaCache.set(stored.Id, codec, 0)
+		if stored.Subject != "" {
+			client.schemaIdCache.set(subjectSchemaKey{stored.Subject, compactSchema(codec)}, stored.Id, 0)
+		}
+	}
+}
+
+// storedSchema returns the stored codec with the given id, nil when it is not stored
+func (client *CachedSchemaRegistryClient) storedSchema(id int) *goavro.Codec {
+	if client.stored == nil {
+		return nil
+	}
+	return client.stored.schema(id)
+}
+
+// storedId returns the stored id of a schema registered to a subject
+func (client *CachedSchemaRegistryClient) storedId(key subjectSchemaKey) (int, bool) {
+	if client.stored == nil {
+		return 0, false
+	}
+	return client.stored.id(key)
+}
+
+// save persists the schema with the given id, registered to subject unless it is empty. The store is best effort
+// so a failing store is reported but does not fail the lookup.
+func (client *CachedSchemaRegistryClient) save(id int, subject string, codec *goavro.Codec) {
+	if client.store == nil {
+		return
+	}
+	client.stored.add(id, subject, codec)
+	if err := client.store.Save(StoredSchema{Id: id, Subject: subject, Schema: codec.Schema()}); err != nil {
+		client.storeError(fmt.Errorf("could not save schema %d: %v", id, err))
+	}
+}
+
+func (client *CachedSchemaRegistryClient) storeError(err error) {
+	if client.storeErrors != nil {
+		client.storeErrors(err)
+	}
 }
@@ -1,7 +1,16 @@
 package kafka
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/linkedin/goavro"
 )
 
 func TestCachedSchemaRegistryClient_GetSchema(t *testing.T) {
@@ -167,3 +176,139 @@ func TestCachedSchemaRegistryClient_DeleteVersion(t *testing.T) {
 		t.Errorf("Error delete version: %v", err)
 	}
 }
+
+func TestCachedSchemaRegistryClient_Context(t *testing.T) {
+	var _ SchemaRegistryClientInterface = &SchemaRegistryClient{}
+	var _ SchemaRegistryClientInterface = &CachedSchemaRegistryClient{}
+	schemaRegistryTestObject := createSchemaRegistryTestObject(t, "test", 1)
+	client := NewCachedSchemaRegistryClient([]string{schemaRegistryTestObject.MockServer.URL}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := client.GetSchemaContext(ctx, 1); err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Errorf("Expected the request to be cancelled, got %v", err)
+	}
+	if schemaRegistryTestObject.Count != 0 {
+		t.Errorf("Expected no request to reach the registry, got %d", schemaRegistryTestObject.Count)
+	}
+	if _, err := client.CreateSubjectContext(ctx, "test", schemaRegistryTestObject.Codec); err == nil {
+		t.Errorf("Expected the registration to be cancelled")
+	}
+	if _, err := client.GetSchemaContext(context.Background(), 1); err != nil {
+		t.Errorf("Found error %s", err)
+	}
+}
+
+func TestCachedSchemaRegistryClient_Cache(t *testing.T) {
+	testObject := createSchemaRegistryTestObject(t, "test", 1)
+	defer testObject.MockServer.Close()
+	client := NewCachedSchemaRegistryClientWithOptions([]string{testObject.MockServer.URL}, WithCacheConfig(CacheConfig{MaxEntries: 10, TTL: time.Minute}))
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetLatestSchema(testObject.Subject); err != nil {
+			t.Errorf("Error getting latest schema: %v", err)
+		}
+		if _, err := client.GetSchemaByVersion(testObject.Subject, 1); err != nil {
+			t.Errorf("Error getting schema version: %v", err)
+		}
+		versions, err := client.GetVersions(testObject.Subject)
+		if err != nil || !containsInt(versions, testObject.Id) {
+			t.Errorf("Error getting versions: %v, %v", versions, err)
+		}
+		versions[0] = 42
+	}
+	if testObject.Count != 3 {
+		t.Errorf("Expected call count of 3, got %d", testObject.Count)
+	}
+
+	if err := client.DeleteVersion(testObject.Subject, 1); err != nil {
+		t.Errorf("Error delete version: %v", err)
+	}
+	client.GetLatestSchema(testObject.Subject)
+	client.GetSchemaByVersion(testObject.Subject, 1)
+	if testObject.Count != 6 {
+		t.Errorf("Expected the deleted version to be fetched again, got call count %d", testObject.Count)
+	}
+}
+
+func TestCachedSchemaRegistryClient_CacheWithoutTTL(t *testing.T) {
+	testObject := createSchemaRegistryTestObject(t, "test", 1)
+	defer testObject.MockServer.Close()
+	client := NewCachedSchemaRegistryClientWithOptions([]string{testObject.MockServer.URL}, WithCacheConfig(CacheConfig{MaxEntries: 1}))
+
+	client.GetLatestSchema(testObject.Subject)
+	client.GetLatestSchema(testObject.Subject)
+	if testObject.Count != 2 {
+		t.Errorf("Expected the latest schema not to be cached, got call count %d", testObject.Count)
+	}
+	client.GetSchema(1)
+	client.GetSchemaByVersion(testObject.Subject, 1)
+	if client.schemaCache.len() != 1 || client.subjectCache.len() != 1 {
+		t.Errorf("Expected the caches to be bounded to 1 entry")
+	}
+}
+
+func TestCachedSchemaRegistryClient_CreateSubjectPerSubject(t *testing.T) {
+	testObject := createSchemaRegistryTestObject(t, "test", 1)
+	defer testObject.MockServer.Close()
+	client := NewCachedSchemaRegistryClient([]string{testObject.MockServer.URL}, nil)
+	reformatted, err := goavro.NewCodec(`{"type":"record","name":"test","fields":[{"name":"val","type":"int","default":0}]}`)
+	if err != nil {
+		t.Fatalf("Could not create codec %v", err)
+	}
+
+	client.CreateSubject("first", testObject.Codec)
+	client.CreateSubject("first", reformatted)
+	client.CreateSubject("second", testObject.Codec)
+	if len(testObject.Registered) != 2 || !containsStr(testObject.Registered, "first") || !containsStr(testObject.Registered, "second") {
+		t.Errorf("Expected the schema to be registered once per subject, got %v", testObject.Registered)
+	}
+
+	client.DeleteSubject("first")
+	client.CreateSubject("first", testObject.Codec)
+	client.CreateSubject("second", reformatted)
+	if len(testObject.Registered) != 3 {
+		t.Errorf("Expected the deleted subject to be registered again, got %v", testObject.Registered)
+	}
+	// a changed default is dropped from the canonical form but is still a new schema
+	withDefault, err := goavro.NewCodec(`{"type": "record", "name": "test", "fields" : [{"name": "val", "type": "int", "default": 1}]}`)
+	if err != nil {
+		t.Fatalf("Could not create codec %v", err)
+	}
+	client.CreateSubject("first", withDefault)
+	if len(testObject.Registered) != 4 {
+		t.Errorf("Expected a schema differing only by its default to be registered, got %v", testObject.Registered)
+	}
+}
+
+func TestCachedSchemaRegistryClient_CoalescedCalls(t *testing.T) {
+	testObject := createSchemaRegistryTestObject(t, "test", 1)
+	defer testObject.MockServer.Close()
+	release := make(chan struct{})
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		testObject.MockServer.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	client := NewCachedSchemaRegistryClient([]string{server.URL}, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.GetSchema(1); err != nil {
+				t.Errorf("Error getting schema: %v", err)
+			}
+		}()
+	}
+	waitFor(t, func() bool { return client.Stats().CoalescedCalls == 9 })
+	close(release)
+	wg.Wait()
+
+	if requests != 1 {
+		t.Errorf("Expected 1 request, got %d", requests)
+	}
+}
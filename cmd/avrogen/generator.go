@@ -0,0 +1,453 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ihsanul14/go-confluent-kafka/internal/avroschema"
+)
+
+// generator emits Go types for avro schemas, with the MarshalAvro, UnmarshalAvro and AvroSchema
+// methods used by the producer and consumer of the kafka package
+type generator struct {
+	pkg     string
+	buf     bytes.Buffer
+	imports map[string]bool
+	goNames map[string]string
+	tmp     int
+}
+
+// input is a top level schema to generate types for
+type input struct {
+	schema *avroschema.Schema
+	source []byte
+}
+
+func newGenerator(pkg string) *generator {
+	return &generator{pkg: pkg, imports: map[string]bool{}, goNames: map[string]string{}}
+}
+
+// generate returns the formatted Go source for the named types of parser, top level records
+// of inputs also get an AvroSchema method
+func (g *generator) generate(parser *avroschema.Parser, inputs []input) ([]byte, error) {
+	for _, named := range parser.Named {
+		name := goName(named.Name)
+		for avroName, other := range g.goNames {
+			if other == name {
+				return nil, fmt.Errorf("avro types %s and %s both map to Go type %s", avroName, named.Name, name)
+			}
+		}
+		g.goNames[named.Name] = name
+	}
+	topLevel := map[*avroschema.Schema][]byte{}
+	for _, in := range inputs {
+		if in.schema.Type == "record" {
+			topLevel[in.schema] = in.source
+		}
+	}
+	for _, named := range parser.Named {
+		switch named.Type {
+		case "record":
+			if err := g.record(named, topLevel[named]); err != nil {
+				return nil, err
+			}
+		case "enum":
+			g.enum(named)
+		case "fixed":
+			g.fixed(named)
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by avrogen. DO NOT EDIT.\n\npackage %s\n\n", g.pkg)
+	if len(g.imports) > 0 {
+		// standard library imports first, then third party ones
+		var std, thirdParty []string
+		for imp := range g.imports {
+			if strings.Contains(imp, ".") {
+				thirdParty = append(thirdParty, imp)
+			} else {
+				std = append(std, imp)
+			}
+		}
+		sort.Strings(std)
+		sort.Strings(thirdParty)
+		out.WriteString("import (\n")
+		for _, imp := range std {
+			fmt.Fprintf(&out, "\t%q\n", imp)
+		}
+		if len(std) > 0 && len(thirdParty) > 0 {
+			out.WriteString("\n")
+		}
+		for _, imp := range thirdParty {
+			fmt.Fprintf(&out, "\t%q\n", imp)
+		}
+		out.WriteString(")\n\n")
+	}
+	out.Write(g.buf.Bytes())
+	return format.Source(out.Bytes())
+}
+
+func (g *generator) p(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format+"\n", args...)
+}
+
+func (g *generator) doc(name string, doc string, kind string, avroName string) {
+	g.p("// %s is generated from the %s avro %s", name, avroName, kind)
+	for _, line := range strings.Split(strings.TrimSpace(doc), "\n") {
+		if line != "" {
+			g.p("// %s", strings.TrimSpace(line))
+		}
+	}
+}
+
+func (g *generator) record(s *avroschema.Schema, source []byte) error {
+	name := g.goNames[s.Name]
+	g.imports["fmt"] = true
+	g.doc(name, s.Doc, "record", s.Name)
+	g.p("type %s struct {", name)
+	for _, f := range s.Fields {
+		if f.Doc != "" {
+			g.p("// %s", strings.Replace(strings.TrimSpace(f.Doc), "\n", " ", -1))
+		}
+		g.p("%s %s `avro:%q`", goName(f.Name), g.goType(f.Type), f.Name)
+	}
+	g.p("}\n")
+
+	if source != nil {
+		compact := new(bytes.Buffer)
+		if err := json.Compact(compact, source); err != nil {
+			return err
+		}
+		g.p("// AvroSchema returns the avro schema %s is encoded with", name)
+		g.p("func (r %s) AvroSchema() string {", name)
+		g.p("return %s", quote(compact.String()))
+		g.p("}\n")
+	}
+
+	g.p("// MarshalAvro converts %s to the goavro native form", name)
+	g.p("func (r %s) MarshalAvro() (interface{}, error) {", name)
+	g.p("return r.avroNative()")
+	g.p("}\n")
+
+	g.p("// UnmarshalAvro populates %s from the goavro native form", name)
+	g.p("func (r *%s) UnmarshalAvro(native interface{}) error {", name)
+	g.p("m, ok := native.(map[string]interface{})")
+	g.p("if !ok {")
+	g.p("return fmt.Errorf(\"%s: cannot decode %%T\", native)", s.Name)
+	g.p("}")
+	g.p("return r.fromAvroNative(m)")
+	g.p("}\n")
+
+	g.tmp = 0
+	g.p("func (r %s) avroNative() (map[string]interface{}, error) {", name)
+	g.p("native := make(map[string]interface{}, %d)", len(s.Fields))
+	for _, f := range s.Fields {
+		g.encode(f.Type, "r."+goName(f.Name), fmt.Sprintf("native[%q]", f.Name), s.Name+"."+f.Name)
+	}
+	g.p("return native, nil")
+	g.p("}\n")
+
+	g.tmp = 0
+	g.p("func (r *%s) fromAvroNative(native map[string]interface{}) error {", name)
+	for _, f := range s.Fields {
+		g.decode(f.Type, fmt.Sprintf("native[%q]", f.Name), "r."+goName(f.Name), s.Name+"."+f.Name)
+	}
+	g.p("return nil")
+	g.p("}\n")
+	return nil
+}
+
+func (g *generator) enum(s *avroschema.Schema) {
+	name := g.goNames[s.Name]
+	g.doc(name, s.Doc, "enum", s.Name)
+	g.p("type %s string\n", name)
+	if len(s.Symbols) == 0 {
+		return
+	}
+	g.p("// %s symbols", name)
+	g.p("const (")
+	for _, symbol := range s.Symbols {
+		g.p("%s%s %s = %q", name, goName(symbol), name, symbol)
+	}
+	g.p(")\n")
+}
+
+func (g *generator) fixed(s *avroschema.Schema) {
+	if s.LogicalType != "" {
+		return
+	}
+	name := g.goNames[s.Name]
+	g.doc(name, s.Doc, "fixed", s.Name)
+	g.p("type %s [%d]byte\n", name, s.Size)
+	g.p("func (f %s) avroNative() []byte {", name)
+	g.p("return f[:]")
+	g.p("}\n")
+}
+
+// goType returns the Go type used for values of s
+func (g *generator) goType(s *avroschema.Schema) string {
+	switch s.LogicalType {
+	case "timestamp-millis", "timestamp-micros", "date":
+		g.imports["time"] = true
+		return "time.Time"
+	case "time-millis", "time-micros":
+		g.imports["time"] = true
+		return "time.Duration"
+	case "decimal":
+		g.imports["math/big"] = true
+		return "*big.Rat"
+	}
+	switch s.Type {
+	case "boolean":
+		return "bool"
+	case "int":
+		return "int32"
+	case "long":
+		return "int64"
+	case "float":
+		return "float32"
+	case "double":
+		return "float64"
+	case "bytes":
+		return "[]byte"
+	case "string":
+		return "string"
+	case "record", "enum", "fixed":
+		return g.goNames[s.Name]
+	case "array":
+		return "[]" + g.goType(s.Items)
+	case "map":
+		return "map[string]" + g.goType(s.Values)
+	case "union":
+		if inner := nullableBranch(s); inner != nil {
+			if nilable(inner) {
+				return g.goType(inner)
+			}
+			return "*" + g.goType(inner)
+		}
+	}
+	// null and unions other than ["null", T] hold the goavro native form
+	return "interface{}"
+}
+
+// nullableBranch returns T for a ["null", T] union
+func nullableBranch(s *avroschema.Schema) *avroschema.Schema {
+	if s.Type != "union" || len(s.Branches) != 2 {
+		return nil
+	}
+	if s.Branches[0].Type == "null" && s.Branches[1].Type != "null" {
+		return s.Branches[1]
+	}
+	if s.Branches[1].Type == "null" && s.Branches[0].Type != "null" {
+		return s.Branches[0]
+	}
+	return nil
+}
+
+// nilable reports whether nil is a valid Go value for s, so a nullable union does not need a pointer
+func nilable(s *avroschema.Schema) bool {
+	switch s.Type {
+	case "bytes", "array", "map", "null":
+		return true
+	case "union":
+		return nullableBranch(s) == nil
+	}
+	return s.LogicalType == "decimal"
+}
+
+func (g *generator) next(prefix string) string {
+	g.tmp++
+	return prefix + strconv.Itoa(g.tmp)
+}
+
+// encode emits statements assigning the native form of src to dst, returning an error from avroNative
+// for values goavro cannot encode
+func (g *generator) encode(s *avroschema.Schema, src string, dst string, path string) {
+	if s.LogicalType == "decimal" {
+		// goavro panics encoding a nil *big.Rat
+		g.p("if %s == nil {", src)
+		g.p("return nil, fmt.Errorf(\"%s: nil decimal\")", path)
+		g.p("}")
+	}
+	if s.LogicalType != "" {
+		g.p("%s = %s", dst, src)
+		return
+	}
+	switch s.Type {
+	case "null":
+		g.p("%s = nil", dst)
+	case "enum":
+		g.p("%s = string(%s)", dst, src)
+	case "record":
+		record := g.next("record")
+		g.p("%s, err := %s.avroNative()", record, src)
+		g.p("if err != nil {")
+		g.p("return nil, err")
+		g.p("}")
+		g.p("%s = %s", dst, record)
+	case "fixed":
+		g.p("%s = %s.avroNative()", dst, src)
+	case "array":
+		items, i, v := g.next("items"), g.next("i"), g.next("v")
+		g.p("%s := make([]interface{}, len(%s))", items, src)
+		g.p("for %s, %s := range %s {", i, v, src)
+		g.encode(s.Items, v, items+"["+i+"]", path+"[]")
+		g.p("}")
+		g.p("%s = %s", dst, items)
+	case "map":
+		values, k, v := g.next("values"), g.next("k"), g.next("v")
+		g.p("%s := make(map[string]interface{}, len(%s))", values, src)
+		g.p("for %s, %s := range %s {", k, v, src)
+		g.encode(s.Values, v, values+"["+k+"]", path+"{}")
+		g.p("}")
+		g.p("%s = %s", dst, values)
+	case "union":
+		inner := nullableBranch(s)
+		if inner == nil {
+			g.p("%s = %s", dst, src)
+			return
+		}
+		g.imports["github.com/linkedin/goavro"] = true
+		g.p("if %s == nil {", src)
+		g.p("%s = nil", dst)
+		g.p("} else {")
+		value, union := src, g.next("union")
+		if !nilable(inner) {
+			value = g.next("v")
+			g.p("%s := *%s", value, src)
+		}
+		g.p("var %s interface{}", union)
+		if inner.LogicalType != "" {
+			// value is not nil here, so a decimal needs no check
+			g.p("%s = %s", union, value)
+		} else {
+			g.encode(inner, value, union, path)
+		}
+		g.p("%s = goavro.Union(%q, %s)", dst, inner.Name, union)
+		g.p("}")
+	default:
+		g.p("%s = %s", dst, src)
+	}
+}
+
+// decode emits statements setting dst from src, an expression of the native form
+func (g *generator) decode(s *avroschema.Schema, src string, dst string, path string) {
+	if s.Type == "union" {
+		inner := nullableBranch(s)
+		if inner == nil {
+			g.p("%s = %s", dst, src)
+			return
+		}
+		g.p("if %s == nil {", src)
+		g.p("%s = nil", dst)
+		g.p("} else {")
+		union, ok, value := g.next("union"), g.next("ok"), g.next("v")
+		g.p("%s, %s := %s.(map[string]interface{})", union, ok, src)
+		g.p("if !%s {", ok)
+		g.p("return fmt.Errorf(\"%s: cannot decode %%T\", %s)", path, src)
+		g.p("}")
+		g.p("var %s %s", value, g.goType(inner))
+		g.decode(inner, fmt.Sprintf("%s[%q]", union, inner.Name), value, path)
+		if nilable(inner) {
+			g.p("%s = %s", dst, value)
+		} else {
+			g.p("%s = &%s", dst, value)
+		}
+		g.p("}")
+		return
+	}
+	if s.Type == "null" {
+		g.p("%s = nil", dst)
+		return
+	}
+
+	nativeType := map[string]string{
+		"boolean": "bool",
+		"int":     "int32",
+		"long":    "int64",
+		"float":   "float32",
+		"double":  "float64",
+		"bytes":   "[]byte",
+		"string":  "string",
+		"enum":    "string",
+		"fixed":   "[]byte",
+		"record":  "map[string]interface{}",
+		"array":   "[]interface{}",
+		"map":     "map[string]interface{}",
+	}[s.Type]
+	if s.LogicalType != "" {
+		nativeType = g.goType(s)
+	}
+	x, ok := g.next("x"), g.next("ok")
+	g.p("%s, %s := %s.(%s)", x, ok, src, nativeType)
+	g.p("if !%s {", ok)
+	g.p("return fmt.Errorf(\"%s: cannot decode %%T\", %s)", path, src)
+	g.p("}")
+	if s.LogicalType != "" {
+		g.p("%s = %s", dst, x)
+		return
+	}
+	switch s.Type {
+	case "enum":
+		g.p("%s = %s(%s)", dst, g.goNames[s.Name], x)
+	case "fixed":
+		g.p("copy(%s[:], %s)", dst, x)
+	case "record":
+		g.p("if err := %s.fromAvroNative(%s); err != nil {", dst, x)
+		g.p("return err")
+		g.p("}")
+	case "array":
+		items, i, v := g.next("items"), g.next("i"), g.next("v")
+		g.p("%s := make(%s, len(%s))", items, g.goType(s), x)
+		g.p("for %s, %s := range %s {", i, v, x)
+		g.decode(s.Items, v, items+"["+i+"]", path+"[]")
+		g.p("}")
+		g.p("%s = %s", dst, items)
+	case "map":
+		values, k, v, value := g.next("values"), g.next("k"), g.next("v"), g.next("value")
+		g.p("%s := make(%s, len(%s))", values, g.goType(s), x)
+		g.p("for %s, %s := range %s {", k, v, x)
+		g.p("var %s %s", value, g.goType(s.Values))
+		g.decode(s.Values, v, value, path+"{}")
+		g.p("%s[%s] = %s", values, k, value)
+		g.p("}")
+		g.p("%s = %s", dst, values)
+	default:
+		g.p("%s = %s", dst, x)
+	}
+}
+
+// goName converts an avro name to an exported Go identifier, dropping the namespace
+func goName(avroName string) string {
+	if i := strings.LastIndex(avroName, "."); i >= 0 {
+		avroName = avroName[i+1:]
+	}
+	parts := strings.FieldsFunc(avroName, func(r rune) bool {
+		return r == '_' || r == '-'
+	})
+	var name string
+	for _, part := range parts {
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		name += string(runes)
+	}
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		name = "X" + name
+	}
+	return name
+}
+
+// quote returns s as a Go string literal, raw when possible
+func quote(s string) string {
+	if strings.Contains(s, "`") {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
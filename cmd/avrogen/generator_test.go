@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "regenerate internal/golden/golden.go")
+
+const testOrderSchema = `{
+	"type": "record",
+	"name": "Order",
+	"namespace": "com.example",
+	"fields": [
+		{"name": "id", "type": "string"},
+		{"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["NEW", "PAID"]}},
+		{"name": "created_at", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+		{"name": "note", "type": ["null", "string"], "default": null},
+		{"name": "hash", "type": {"type": "fixed", "name": "Hash", "size": 16}},
+		{"name": "lines", "type": {"type": "array", "items": {"type": "record", "name": "Line", "fields": [{"name": "sku", "type": "string"}]}}}
+	]
+}`
+
+func TestGenerate(t *testing.T) {
+	code, err := generate("events", [][]byte{[]byte(testOrderSchema)})
+	if err != nil {
+		t.Fatalf("Error generating code: %v", err)
+	}
+	expected := []string{
+		"// Code generated by avrogen. DO NOT EDIT.",
+		"package events",
+		"type Order struct {",
+		"CreatedAt time.Time `avro:\"created_at\"`",
+		"Note      *string   `avro:\"note\"`",
+		"Lines     []Line    `avro:\"lines\"`",
+		"func (r Order) AvroSchema() string {",
+		"func (r Order) MarshalAvro() (interface{}, error) {",
+		"func (r *Order) UnmarshalAvro(native interface{}) error {",
+		"native[\"note\"] = goavro.Union(\"string\", union",
+		"StatusPAID Status = \"PAID\"",
+		"type Hash [16]byte",
+		"type Line struct {",
+	}
+	for _, e := range expected {
+		if !strings.Contains(string(code), e) {
+			t.Errorf("Expected generated code to contain %q, got:\n%s", e, code)
+		}
+	}
+	if strings.Contains(string(code), "func (r Line) AvroSchema()") {
+		t.Errorf("Expected AvroSchema only for top level records")
+	}
+}
+
+func TestGenerate_Golden(t *testing.T) {
+	schema, err := ioutil.ReadFile("internal/golden/order.avsc")
+	if err != nil {
+		t.Fatalf("Could not read schema: %v", err)
+	}
+	code, err := generate("golden", [][]byte{schema})
+	if err != nil {
+		t.Fatalf("Error generating code: %v", err)
+	}
+	if *update {
+		if err := ioutil.WriteFile("internal/golden/golden.go", code, 0644); err != nil {
+			t.Fatalf("Could not update golden file: %v", err)
+		}
+	}
+	golden, err := ioutil.ReadFile("internal/golden/golden.go")
+	if err != nil {
+		t.Fatalf("Could not read golden file: %v", err)
+	}
+	if !bytes.Equal(code, golden) {
+		t.Errorf("Generated code differs from internal/golden/golden.go, run go test -update to regenerate it, got:\n%s", code)
+	}
+}
+
+func TestGenerate_References(t *testing.T) {
+	customer := `{"type": "record", "name": "Customer", "namespace": "com.example", "fields": [{"name": "name", "type": "string"}]}`
+	order := `{"type": "record", "name": "Order", "namespace": "com.example", "fields": [{"name": "customer", "type": "Customer"}]}`
+	code, err := generate("events", [][]byte{[]byte(customer), []byte(order)})
+	if err != nil {
+		t.Fatalf("Error generating code: %v", err)
+	}
+	if !strings.Contains(string(code), "Customer Customer `avro:\"customer\"`") {
+		t.Errorf("Expected reference to Customer, got:\n%s", code)
+	}
+	if _, err := generate("events", [][]byte{[]byte(order)}); err == nil {
+		t.Errorf("Expected error for unknown type")
+	}
+}
+
+func TestGenerate_NameCollision(t *testing.T) {
+	schema := `{"type": "record", "name": "Order", "namespace": "a", "fields": [
+		{"name": "other", "type": {"type": "record", "name": "Order", "namespace": "b", "fields": []}}
+	]}`
+	if _, err := generate("events", [][]byte{[]byte(schema)}); err == nil {
+		t.Errorf("Expected error for types mapping to the same Go name")
+	}
+}
+
+func TestGoName(t *testing.T) {
+	tests := map[string]string{
+		"com.example.order_created": "OrderCreated",
+		"created_at":                "CreatedAt",
+		"id":                        "Id",
+		"in-progress":               "InProgress",
+		"_1st":                      "X1st",
+	}
+	for avroName, expected := range tests {
+		if name := goName(avroName); name != expected {
+			t.Errorf("Names do not match. Expected: %s, got: %s", expected, name)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+// Package golden holds the code avrogen generates for order.avsc, its tests compile the generated types
+// and round-trip them through goavro. TestGenerate_Golden fails when golden.go is outdated, run
+// go test -update in cmd/avrogen to regenerate it.
+package golden
+
+//go:generate go run ../.. -package golden -out golden.go order.avsc
@@ -0,0 +1,352 @@
+// Code generated by avrogen. DO NOT EDIT.
+
+package golden
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/linkedin/goavro"
+)
+
+// Order is generated from the com.example.Order avro record
+// An order placed by a customer
+type Order struct {
+	Id        string    `avro:"id"`
+	Paid      bool      `avro:"paid"`
+	Quantity  int32     `avro:"quantity"`
+	Sequence  int64     `avro:"sequence"`
+	Weight    float32   `avro:"weight"`
+	Price     float64   `avro:"price"`
+	Payload   []byte    `avro:"payload"`
+	Status    Status    `avro:"status"`
+	Hash      Hash      `avro:"hash"`
+	CreatedAt time.Time `avro:"created_at"`
+	Due       time.Time `avro:"due"`
+	Total     *big.Rat  `avro:"total"`
+	Discount  *big.Rat  `avro:"discount"`
+	// Free text from the customer
+	Note       *string          `avro:"note"`
+	Customer   *Customer        `avro:"customer"`
+	Lines      []Line           `avro:"lines"`
+	Attributes map[string]int64 `avro:"attributes"`
+	Reference  interface{}      `avro:"reference"`
+}
+
+// AvroSchema returns the avro schema Order is encoded with
+func (r Order) AvroSchema() string {
+	return `{"type":"record","name":"Order","namespace":"com.example","doc":"An order placed by a customer","fields":[{"name":"id","type":"string"},{"name":"paid","type":"boolean"},{"name":"quantity","type":"int"},{"name":"sequence","type":"long"},{"name":"weight","type":"float"},{"name":"price","type":"double"},{"name":"payload","type":"bytes"},{"name":"status","type":{"type":"enum","name":"Status","symbols":["NEW","PAID"]}},{"name":"hash","type":{"type":"fixed","name":"Hash","size":4}},{"name":"created_at","type":{"type":"long","logicalType":"timestamp-millis"}},{"name":"due","type":{"type":"int","logicalType":"date"}},{"name":"total","type":{"type":"bytes","logicalType":"decimal","precision":10,"scale":2}},{"name":"discount","type":["null",{"type":"bytes","logicalType":"decimal","precision":10,"scale":2}],"default":null},{"name":"note","type":["null","string"],"default":null,"doc":"Free text from the customer"},{"name":"customer","type":["null",{"type":"record","name":"Customer","fields":[{"name":"name","type":"string"}]}],"default":null},{"name":"lines","type":{"type":"array","items":{"type":"record","name":"Line","fields":[{"name":"sku","type":"string"},{"name":"amount","type":{"type":"bytes","logicalType":"decimal","precision":10,"scale":2}}]}}},{"name":"attributes","type":{"type":"map","values":"long"}},{"name":"reference","type":["string","long"]}]}`
+}
+
+// MarshalAvro converts Order to the goavro native form
+func (r Order) MarshalAvro() (interface{}, error) {
+	return r.avroNative()
+}
+
+// UnmarshalAvro populates Order from the goavro native form
+func (r *Order) UnmarshalAvro(native interface{}) error {
+	m, ok := native.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("com.example.Order: cannot decode %T", native)
+	}
+	return r.fromAvroNative(m)
+}
+
+func (r Order) avroNative() (map[string]interface{}, error) {
+	native := make(map[string]interface{}, 18)
+	native["id"] = r.Id
+	native["paid"] = r.Paid
+	native["quantity"] = r.Quantity
+	native["sequence"] = r.Sequence
+	native["weight"] = r.Weight
+	native["price"] = r.Price
+	native["payload"] = r.Payload
+	native["status"] = string(r.Status)
+	native["hash"] = r.Hash.avroNative()
+	native["created_at"] = r.CreatedAt
+	native["due"] = r.Due
+	if r.Total == nil {
+		return nil, fmt.Errorf("com.example.Order.total: nil decimal")
+	}
+	native["total"] = r.Total
+	if r.Discount == nil {
+		native["discount"] = nil
+	} else {
+		var union1 interface{}
+		union1 = r.Discount
+		native["discount"] = goavro.Union("bytes.decimal", union1)
+	}
+	if r.Note == nil {
+		native["note"] = nil
+	} else {
+		v3 := *r.Note
+		var union2 interface{}
+		union2 = v3
+		native["note"] = goavro.Union("string", union2)
+	}
+	if r.Customer == nil {
+		native["customer"] = nil
+	} else {
+		v5 := *r.Customer
+		var union4 interface{}
+		record6, err := v5.avroNative()
+		if err != nil {
+			return nil, err
+		}
+		union4 = record6
+		native["customer"] = goavro.Union("com.example.Customer", union4)
+	}
+	items7 := make([]interface{}, len(r.Lines))
+	for i8, v9 := range r.Lines {
+		record10, err := v9.avroNative()
+		if err != nil {
+			return nil, err
+		}
+		items7[i8] = record10
+	}
+	native["lines"] = items7
+	values11 := make(map[string]interface{}, len(r.Attributes))
+	for k12, v13 := range r.Attributes {
+		values11[k12] = v13
+	}
+	native["attributes"] = values11
+	native["reference"] = r.Reference
+	return native, nil
+}
+
+func (r *Order) fromAvroNative(native map[string]interface{}) error {
+	x1, ok2 := native["id"].(string)
+	if !ok2 {
+		return fmt.Errorf("com.example.Order.id: cannot decode %T", native["id"])
+	}
+	r.Id = x1
+	x3, ok4 := native["paid"].(bool)
+	if !ok4 {
+		return fmt.Errorf("com.example.Order.paid: cannot decode %T", native["paid"])
+	}
+	r.Paid = x3
+	x5, ok6 := native["quantity"].(int32)
+	if !ok6 {
+		return fmt.Errorf("com.example.Order.quantity: cannot decode %T", native["quantity"])
+	}
+	r.Quantity = x5
+	x7, ok8 := native["sequence"].(int64)
+	if !ok8 {
+		return fmt.Errorf("com.example.Order.sequence: cannot decode %T", native["sequence"])
+	}
+	r.Sequence = x7
+	x9, ok10 := native["weight"].(float32)
+	if !ok10 {
+		return fmt.Errorf("com.example.Order.weight: cannot decode %T", native["weight"])
+	}
+	r.Weight = x9
+	x11, ok12 := native["price"].(float64)
+	if !ok12 {
+		return fmt.Errorf("com.example.Order.price: cannot decode %T", native["price"])
+	}
+	r.Price = x11
+	x13, ok14 := native["payload"].([]byte)
+	if !ok14 {
+		return fmt.Errorf("com.example.Order.payload: cannot decode %T", native["payload"])
+	}
+	r.Payload = x13
+	x15, ok16 := native["status"].(string)
+	if !ok16 {
+		return fmt.Errorf("com.example.Order.status: cannot decode %T", native["status"])
+	}
+	r.Status = Status(x15)
+	x17, ok18 := native["hash"].([]byte)
+	if !ok18 {
+		return fmt.Errorf("com.example.Order.hash: cannot decode %T", native["hash"])
+	}
+	copy(r.Hash[:], x17)
+	x19, ok20 := native["created_at"].(time.Time)
+	if !ok20 {
+		return fmt.Errorf("com.example.Order.created_at: cannot decode %T", native["created_at"])
+	}
+	r.CreatedAt = x19
+	x21, ok22 := native["due"].(time.Time)
+	if !ok22 {
+		return fmt.Errorf("com.example.Order.due: cannot decode %T", native["due"])
+	}
+	r.Due = x21
+	x23, ok24 := native["total"].(*big.Rat)
+	if !ok24 {
+		return fmt.Errorf("com.example.Order.total: cannot decode %T", native["total"])
+	}
+	r.Total = x23
+	if native["discount"] == nil {
+		r.Discount = nil
+	} else {
+		union25, ok26 := native["discount"].(map[string]interface{})
+		if !ok26 {
+			return fmt.Errorf("com.example.Order.discount: cannot decode %T", native["discount"])
+		}
+		var v27 *big.Rat
+		x28, ok29 := union25["bytes.decimal"].(*big.Rat)
+		if !ok29 {
+			return fmt.Errorf("com.example.Order.discount: cannot decode %T", union25["bytes.decimal"])
+		}
+		v27 = x28
+		r.Discount = v27
+	}
+	if native["note"] == nil {
+		r.Note = nil
+	} else {
+		union30, ok31 := native["note"].(map[string]interface{})
+		if !ok31 {
+			return fmt.Errorf("com.example.Order.note: cannot decode %T", native["note"])
+		}
+		var v32 string
+		x33, ok34 := union30["string"].(string)
+		if !ok34 {
+			return fmt.Errorf("com.example.Order.note: cannot decode %T", union30["string"])
+		}
+		v32 = x33
+		r.Note = &v32
+	}
+	if native["customer"] == nil {
+		r.Customer = nil
+	} else {
+		union35, ok36 := native["customer"].(map[string]interface{})
+		if !ok36 {
+			return fmt.Errorf("com.example.Order.customer: cannot decode %T", native["customer"])
+		}
+		var v37 Customer
+		x38, ok39 := union35["com.example.Customer"].(map[string]interface{})
+		if !ok39 {
+			return fmt.Errorf("com.example.Order.customer: cannot decode %T", union35["com.example.Customer"])
+		}
+		if err := v37.fromAvroNative(x38); err != nil {
+			return err
+		}
+		r.Customer = &v37
+	}
+	x40, ok41 := native["lines"].([]interface{})
+	if !ok41 {
+		return fmt.Errorf("com.example.Order.lines: cannot decode %T", native["lines"])
+	}
+	items42 := make([]Line, len(x40))
+	for i43, v44 := range x40 {
+		x45, ok46 := v44.(map[string]interface{})
+		if !ok46 {
+			return fmt.Errorf("com.example.Order.lines[]: cannot decode %T", v44)
+		}
+		if err := items42[i43].fromAvroNative(x45); err != nil {
+			return err
+		}
+	}
+	r.Lines = items42
+	x47, ok48 := native["attributes"].(map[string]interface{})
+	if !ok48 {
+		return fmt.Errorf("com.example.Order.attributes: cannot decode %T", native["attributes"])
+	}
+	values49 := make(map[string]int64, len(x47))
+	for k50, v51 := range x47 {
+		var value52 int64
+		x53, ok54 := v51.(int64)
+		if !ok54 {
+			return fmt.Errorf("com.example.Order.attributes{}: cannot decode %T", v51)
+		}
+		value52 = x53
+		values49[k50] = value52
+	}
+	r.Attributes = values49
+	r.Reference = native["reference"]
+	return nil
+}
+
+// Status is generated from the com.example.Status avro enum
+type Status string
+
+// Status symbols
+const (
+	StatusNEW  Status = "NEW"
+	StatusPAID Status = "PAID"
+)
+
+// Hash is generated from the com.example.Hash avro fixed
+type Hash [4]byte
+
+func (f Hash) avroNative() []byte {
+	return f[:]
+}
+
+// Customer is generated from the com.example.Customer avro record
+type Customer struct {
+	Name string `avro:"name"`
+}
+
+// MarshalAvro converts Customer to the goavro native form
+func (r Customer) MarshalAvro() (interface{}, error) {
+	return r.avroNative()
+}
+
+// UnmarshalAvro populates Customer from the goavro native form
+func (r *Customer) UnmarshalAvro(native interface{}) error {
+	m, ok := native.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("com.example.Customer: cannot decode %T", native)
+	}
+	return r.fromAvroNative(m)
+}
+
+func (r Customer) avroNative() (map[string]interface{}, error) {
+	native := make(map[string]interface{}, 1)
+	native["name"] = r.Name
+	return native, nil
+}
+
+func (r *Customer) fromAvroNative(native map[string]interface{}) error {
+	x1, ok2 := native["name"].(string)
+	if !ok2 {
+		return fmt.Errorf("com.example.Customer.name: cannot decode %T", native["name"])
+	}
+	r.Name = x1
+	return nil
+}
+
+// Line is generated from the com.example.Line avro record
+type Line struct {
+	Sku    string   `avro:"sku"`
+	Amount *big.Rat `avro:"amount"`
+}
+
+// MarshalAvro converts Line to the goavro native form
+func (r Line) MarshalAvro() (interface{}, error) {
+	return r.avroNative()
+}
+
+// UnmarshalAvro populates Line from the goavro native form
+func (r *Line) UnmarshalAvro(native interface{}) error {
+	m, ok := native.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("com.example.Line: cannot decode %T", native)
+	}
+	return r.fromAvroNative(m)
+}
+
+func (r Line) avroNative() (map[string]interface{}, error) {
+	native := make(map[string]interface{}, 2)
+	native["sku"] = r.Sku
+	if r.Amount == nil {
+		return nil, fmt.Errorf("com.example.Line.amount: nil decimal")
+	}
+	native["amount"] = r.Amount
+	return native, nil
+}
+
+func (r *Line) fromAvroNative(native map[string]interface{}) error {
+	x1, ok2 := native["sku"].(string)
+	if !ok2 {
+		return fmt.Errorf("com.example.Line.sku: cannot decode %T", native["sku"])
+	}
+	r.Sku = x1
+	x3, ok4 := native["amount"].(*big.Rat)
+	if !ok4 {
+		return fmt.Errorf("com.example.Line.amount: cannot decode %T", native["amount"])
+	}
+	r.Amount = x3
+	return nil
+}
@@ -0,0 +1,81 @@
+package golden
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/linkedin/goavro"
+)
+
+func TestOrder_RoundTrip(t *testing.T) {
+	codec, err := goavro.NewCodec(Order{}.AvroSchema())
+	if err != nil {
+		t.Fatalf("Could not create codec %v", err)
+	}
+	note := "fragile"
+	order := Order{
+		Id:         "1",
+		Paid:       true,
+		Quantity:   3,
+		Sequence:   1 << 40,
+		Weight:     1.5,
+		Price:      9.99,
+		Payload:    []byte{1, 2},
+		Status:     StatusPAID,
+		Hash:       Hash{1, 2, 3, 4},
+		CreatedAt:  time.Unix(1500000000, 0).UTC(),
+		Due:        time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC),
+		Total:      big.NewRat(1999, 100),
+		Discount:   big.NewRat(5, 2),
+		Note:       &note,
+		Customer:   &Customer{"jane"},
+		Lines:      []Line{{"sku-1", big.NewRat(1999, 100)}},
+		Attributes: map[string]int64{"weight": 2},
+		Reference:  goavro.Union("long", int64(7)),
+	}
+	native, err := order.MarshalAvro()
+	if err != nil {
+		t.Fatalf("Error marshaling avro: %v", err)
+	}
+	binary, err := codec.BinaryFromNative(nil, native)
+	if err != nil {
+		t.Fatalf("Error encoding native: %v", err)
+	}
+	decoded, _, err := codec.NativeFromBinary(binary)
+	if err != nil {
+		t.Fatalf("Error decoding binary: %v", err)
+	}
+	var result Order
+	if err := result.UnmarshalAvro(decoded); err != nil {
+		t.Fatalf("Error unmarshaling avro: %v", err)
+	}
+	if result.Total.Cmp(order.Total) != 0 || result.Discount.Cmp(order.Discount) != 0 || result.Lines[0].Amount.Cmp(order.Lines[0].Amount) != 0 {
+		t.Errorf("Decimals do not match. Expected: %v %v %v, got: %v %v %v", order.Total, order.Discount, order.Lines[0].Amount,
+			result.Total, result.Discount, result.Lines[0].Amount)
+	}
+	result.Total, result.Discount, result.Lines[0].Amount = order.Total, order.Discount, order.Lines[0].Amount
+	result.CreatedAt, result.Due = result.CreatedAt.UTC(), result.Due.UTC()
+	if !reflect.DeepEqual(order, result) {
+		t.Errorf("Orders do not match. Expected: %+v, got: %+v", order, result)
+	}
+}
+
+func TestOrder_NilDecimal(t *testing.T) {
+	if _, err := (Order{}).MarshalAvro(); err == nil {
+		t.Errorf("Expected error marshaling a nil decimal")
+	}
+	order := Order{Total: big.NewRat(1, 1), Lines: []Line{{Sku: "sku-1"}}}
+	if _, err := order.MarshalAvro(); err == nil {
+		t.Errorf("Expected error marshaling a nil decimal of an array item")
+	}
+	order.Lines = nil
+	native, err := order.MarshalAvro()
+	if err != nil {
+		t.Fatalf("Error marshaling avro: %v", err)
+	}
+	if native.(map[string]interface{})["discount"] != nil {
+		t.Errorf("Expected a nil nullable decimal to be encoded as null")
+	}
+}
@@ -0,0 +1,95 @@
+// Command avrogen generates Go types from avro schemas, read from .avsc files or from a schema registry subject.
+// The generated types implement AvroSchema, MarshalAvro and UnmarshalAvro, so they can be passed to
+// AvroProducer.Produce and Message.Decode without reflection.
+//
+//	avrogen -package events -out events.go order.avsc payment.avsc
+//	avrogen -package events -out orders.go -registry http://localhost:8081 -subject orders-value -version 3
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	kafka "github.com/ihsanul14/go-confluent-kafka"
+	"github.com/ihsanul14/go-confluent-kafka/internal/avroschema"
+)
+
+func main() {
+	pkg := flag.String("package", "main", "package name of the generated file")
+	out := flag.String("out", "", "output file, stdout when empty")
+	registry := flag.String("registry", "", "schema registry url to read the schema of -subject from")
+	subject := flag.String("subject", "", "schema registry subject")
+	version := flag.Int("version", 0, "subject version, the latest version when 0")
+	flag.Parse()
+
+	if err := run(*pkg, *out, *registry, *subject, *version, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "avrogen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(pkg, out, registry, subject string, version int, files []string) error {
+	var sources [][]byte
+	for _, file := range files {
+		source, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, source)
+	}
+	if registry != "" {
+		source, err := registrySchema(registry, subject, version)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, source)
+	}
+	if len(sources) == 0 {
+		return fmt.Errorf("no schema given, pass .avsc files or -registry and -subject")
+	}
+
+	code, err := generate(pkg, sources)
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = os.Stdout.Write(code)
+		return err
+	}
+	return ioutil.WriteFile(out, code, 0644)
+}
+
+// generate returns the Go source for the schemas, later schemas may reference types declared by earlier ones
+func generate(pkg string, sources [][]byte) ([]byte, error) {
+	parser := avroschema.NewParser()
+	var inputs []input
+	for _, source := range sources {
+		s, err := parser.Parse(source)
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, input{s, source})
+	}
+	return newGenerator(pkg).generate(parser, inputs)
+}
+
+func registrySchema(registry, subject string, version int) ([]byte, error) {
+	if subject == "" {
+		return nil, fmt.Errorf("-subject is required with -registry")
+	}
+	client := kafka.NewSchemaRegistryClient([]string{registry}, nil)
+	if version == 0 {
+		codec, err := client.GetLatestSchema(subject)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(codec.Schema()), nil
+	}
+	codec, err := client.GetSchemaByVersion(subject, version)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(codec.Schema()), nil
+}
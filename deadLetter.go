@@ -0,0 +1,71 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+
+	"github.com/Shopify/sarama"
+)
+
+// DeadLetterConfig republishes the messages the consumer fails to decode or handle to Topic,
+// with their original key, value and headers
+type DeadLetterConfig struct {
+	Topic string
+}
+
+// headers added to dead letter messages, describing where they came from and why they failed
+const (
+	DeadLetterHeaderTopic     = "dlq.source.topic"
+	DeadLetterHeaderPartition = "dlq.source.partition"
+	DeadLetterHeaderOffset    = "dlq.source.offset"
+	// DeadLetterHeaderSchemaId is only set when the value is in the confluent avro wire format
+	DeadLetterHeaderSchemaId     = "dlq.schema.id"
+	DeadLetterHeaderErrorClass   = "dlq.error.class"
+	DeadLetterHeaderErrorMessage = "dlq.error.message"
+	DeadLetterHeaderErrorStack   = "dlq.error.stack"
+)
+
+// deadLetter returns a FailurePolicy.DeadLetter sending the failed messages to topic
+func deadLetter(producer *AvroProducer, topic string) func(m *sarama.ConsumerMessage, err error) error {
+	return func(m *sarama.ConsumerMessage, err error) error {
+		_, _, sendErr := producer.producer.SendMessage(newDeadLetterMessage(topic, m, err))
+		return sendErr
+	}
+}
+
+// newDeadLetterMessage copies m to topic and describes its source and err in the headers
+func newDeadLetterMessage(topic string, m *sarama.ConsumerMessage, err error) *sarama.ProducerMessage {
+	msg := &sarama.ProducerMessage{
+		Topic: topic,
+		Value: sarama.ByteEncoder(m.Value),
+	}
+	if m.Key != nil {
+		msg.Key = sarama.ByteEncoder(m.Key)
+	}
+	for _, header := range m.Headers {
+		msg.Headers = append(msg.Headers, *header)
+	}
+	addHeader := func(key string, value string) {
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+	}
+	addHeader(DeadLetterHeaderTopic, m.Topic)
+	addHeader(DeadLetterHeaderPartition, strconv.Itoa(int(m.Partition)))
+	addHeader(DeadLetterHeaderOffset, strconv.FormatInt(m.Offset, 10))
+	if isAvroEncoded(m.Value) {
+		addHeader(DeadLetterHeaderSchemaId, strconv.Itoa(int(binary.BigEndian.Uint32(m.Value[1:5]))))
+	}
+	addHeader(DeadLetterHeaderErrorClass, fmt.Sprintf("%T", err))
+	addHeader(DeadLetterHeaderErrorMessage, err.Error())
+	addHeader(DeadLetterHeaderErrorStack, errorStack(err))
+	return msg
+}
+
+// errorStack returns the stack of a handler panic, other errors are formatted with %+v
+// which prints the stack of errors recording one, like those of github.com/pkg/errors
+func errorStack(err error) string {
+	if panicErr, ok := err.(*PanicError); ok {
+		return string(panicErr.Stack)
+	}
+	return fmt.Sprintf("%+v", err)
+}
@@ -0,0 +1,104 @@
+package kafka
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+)
+
+func TestNewDeadLetterMessage(t *testing.T) {
+	m := &sarama.ConsumerMessage{
+		Topic:     "test",
+		Partition: 2,
+		Offset:    42,
+		Key:       []byte("key"),
+		Value:     []byte{0, 0, 0, 0, 7, 1, 2},
+		Headers:   []*sarama.RecordHeader{{Key: []byte("trace"), Value: []byte("abc")}},
+	}
+	msg := newDeadLetterMessage("test-dlq", m, ErrInvalidAvroMessage)
+
+	if msg.Topic != "test-dlq" {
+		t.Errorf("Expected topic test-dlq, got %s", msg.Topic)
+	}
+	if key, _ := msg.Key.Encode(); string(key) != "key" {
+		t.Errorf("Expected the original key, got %s", key)
+	}
+	if value, _ := msg.Value.Encode(); !bytes.Equal(value, m.Value) {
+		t.Errorf("Expected the original value, got %v", value)
+	}
+	headers := map[string]string{}
+	for _, header := range msg.Headers {
+		headers[string(header.Key)] = string(header.Value)
+	}
+	expected := map[string]string{
+		"trace":                      "abc",
+		DeadLetterHeaderTopic:        "test",
+		DeadLetterHeaderPartition:    "2",
+		DeadLetterHeaderOffset:       "42",
+		DeadLetterHeaderSchemaId:     "7",
+		DeadLetterHeaderErrorClass:   "*errors.errorString",
+		DeadLetterHeaderErrorMessage: ErrInvalidAvroMessage.Error(),
+		DeadLetterHeaderErrorStack:   ErrInvalidAvroMessage.Error(),
+	}
+	for key, value := range expected {
+		if headers[key] != value {
+			t.Errorf("Expected header %s to be %q, got %q", key, value, headers[key])
+		}
+	}
+
+	msg = newDeadLetterMessage("test-dlq", &sarama.ConsumerMessage{Value: []byte("not avro")}, errors.New("failed"))
+	if msg.Key != nil {
+		t.Errorf("Expected no key, got %v", msg.Key)
+	}
+	for _, header := range msg.Headers {
+		if string(header.Key) == DeadLetterHeaderSchemaId {
+			t.Errorf("Expected no schema id header for a value that is not avro encoded")
+		}
+	}
+}
+
+func TestAvroConsumer_DeadLetter(t *testing.T) {
+	schemaRegistryTestObject := createSchemaRegistryTestObject(t, "test", 1)
+	schemaRegistryMock := NewCachedSchemaRegistryClient([]string{schemaRegistryTestObject.MockServer.URL}, nil)
+	producerMock := mocks.NewSyncProducer(t, nil)
+	producerMock.ExpectSendMessageWithCheckerFunctionAndSucceed(func(val []byte) error {
+		if string(val) != "not avro" {
+			return errors.New("expected the original value")
+		}
+		return nil
+	})
+	producerMock.ExpectSendMessageAndSucceed()
+	deadLetterProducer := &AvroProducer{producerMock, schemaRegistryMock, nil}
+	policy := FailurePolicy{Action: FailureDeadLetter, DeadLetter: deadLetter(deadLetterProducer, "test-dlq")}
+	var errs []error
+	callbacks := ConsumerCallbacks{
+		OnMessage: func(msg Message) error {
+			panic("handler bug")
+		},
+		OnError: func(err error) { errs = append(errs, err) },
+	}
+	ac := &avroConsumer{nil, schemaRegistryMock, callbacks, []string{"test"}, policy, deadLetterProducer}
+	session := &testConsumerGroupSession{}
+	claim := newTestConsumerGroupClaim(
+		&sarama.ConsumerMessage{Value: []byte("not avro"), Topic: "test", Offset: 0},
+		&sarama.ConsumerMessage{Value: getTestAvroMsg(t, schemaRegistryTestObject.Codec), Topic: "test", Offset: 1},
+	)
+	if err := (&avroConsumerGroupHandler{ac}).ConsumeClaim(session, claim); err != nil {
+		t.Errorf("Error consuming claim: %v", err)
+	}
+	if len(session.marked) != 2 {
+		t.Errorf("Expected both offsets to be marked, got %v", session.marked)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 errors, got %v", errs)
+	}
+	panicErr, ok := errs[1].(*PanicError)
+	if !ok || panicErr.Value != "handler bug" || !strings.Contains(errorStack(panicErr), "handleMessage") {
+		t.Errorf("Expected the handler panic with its stack, got %v", errs[1])
+	}
+	deadLetterProducer.Close()
+}
@@ -2,10 +2,26 @@ package kafka
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
 )
 
+// ErrInvalidAvroMessage is returned when a message does not carry the magic byte and schema id header
+var ErrInvalidAvroMessage = errors.New("message is not in the confluent avro wire format")
+
+// ErrProducerClosed is returned when a message is added to a closed producer
+var ErrProducerClosed = errors.New("producer is closed")
+
+// PanicError is returned when a consumer handler panics, Stack is the stack of the panic
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("handler panic: %v", e.Value)
+}
+
 // Error holds more detailed information about errors coming back from schema registry
 type Error struct {
 	ErrorCode int    `json:"error_code"`
@@ -16,11 +32,11 @@ func (e *Error) Error() string {
 	return fmt.Sprintf("%d - %s", e.ErrorCode, e.Message)
 }
 
-func newError(resp *http.Response) *Error {
+func newError(statusCode int, body []byte) *Error {
 	err := &Error{}
-	parsingErr := json.NewDecoder(resp.Body).Decode(&err)
+	parsingErr := json.Unmarshal(body, &err)
 	if parsingErr != nil {
-		return &Error{resp.StatusCode, "Unrecognized error found"}
+		return &Error{statusCode, "Unrecognized error found"}
 	}
 	return err
 }
@@ -0,0 +1,50 @@
+package kafka
+
+import (
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// FailureAction is applied to a message whose decoding or handling still fails after the retries
+type FailureAction int
+
+const (
+	// FailureStopPartition reports the error to OnError and stops consuming the partition without marking
+	// the offset, the message is consumed again after the next rebalance or restart. It is the default,
+	// so no message is lost unless a policy says otherwise.
+	FailureStopPartition FailureAction = iota
+	// FailureSkip reports the error to OnError, marks the offset and continues with the next message
+	FailureSkip
+	// FailureDeadLetter passes the message to FailurePolicy.DeadLetter and marks the offset once it returns nil,
+	// when it fails the partition is stopped as with FailureStopPartition
+	FailureDeadLetter
+)
+
+// FailurePolicy decides what happens to a message whose decoding or handling failed
+type FailurePolicy struct {
+	// Retries is the number of times a failed message is decoded and handled again before Action is applied
+	Retries int
+	// Backoff is the delay before the first retry, it is doubled for every following retry
+	Backoff time.Duration
+	// MaxBackoff caps the delay between retries, no cap when 0
+	MaxBackoff time.Duration
+	Action     FailureAction
+	// DeadLetter receives the failed message and the last error when Action is FailureDeadLetter
+	DeadLetter func(m *sarama.ConsumerMessage, err error) error
+}
+
+// backoff returns the delay before the given retry, starting at 1
+func (p FailurePolicy) backoff(retry int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < retry; i++ {
+		delay *= 2
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,20 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFailurePolicy_backoff(t *testing.T) {
+	policy := FailurePolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i, delay := range expected {
+		if backoff := policy.backoff(i + 1); backoff != delay {
+			t.Errorf("Expected backoff %v for retry %d, got %v", delay, i+1, backoff)
+		}
+	}
+	policy.MaxBackoff = 0
+	if backoff := policy.backoff(5); backoff != 1600*time.Millisecond {
+		t.Errorf("Expected uncapped backoff 1.6s, got %v", backoff)
+	}
+}
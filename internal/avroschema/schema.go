@@ -0,0 +1,187 @@
+// Package avroschema parses avro schemas for the kafka package and the avrogen command,
+// so both resolve names, unions and logical types the same way goavro does.
+package avroschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Schema is a parsed avro schema, named types are shared between the schemas referencing them.
+// Name is the full name of named types and the goavro name of the others, which is also the key
+// of their union branch.
+type Schema struct {
+	Type        string
+	Name        string
+	Doc         string
+	LogicalType string
+	Fields      []*Field
+	Symbols     []string
+	Size        int
+	Items       *Schema
+	Values      *Schema
+	Branches    []*Schema
+}
+
+// Field is a field of a record schema
+type Field struct {
+	Name       string
+	Doc        string
+	Type       *Schema
+	Aliases    []string
+	HasDefault bool
+}
+
+// Parser parses schemas and keeps the named types in declaration order,
+// so later schemas can reference types declared by earlier ones
+type Parser struct {
+	names map[string]*Schema
+	// Named holds the named types in declaration order
+	Named []*Schema
+}
+
+func NewParser() *Parser {
+	return &Parser{names: map[string]*Schema{}}
+}
+
+// Parse parses a single schema in its json form
+func Parse(data []byte) (*Schema, error) {
+	return NewParser().Parse(data)
+}
+
+// LogicalTypes are the logical types goavro converts to Go types, others are handled as their underlying type
+var LogicalTypes = map[string]bool{
+	"timestamp-millis": true,
+	"timestamp-micros": true,
+	"time-millis":      true,
+	"time-micros":      true,
+	"date":             true,
+	"decimal":          true,
+}
+
+// Parse parses a schema in its json form, it may reference the named types of the schemas parsed before
+func (p *Parser) Parse(data []byte) (*Schema, error) {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	return p.build(raw, "")
+}
+
+func (p *Parser) build(raw interface{}, namespace string) (*Schema, error) {
+	switch raw := raw.(type) {
+	case string:
+		switch raw {
+		case "null", "boolean", "int", "long", "float", "double", "bytes", "string":
+			return &Schema{Type: raw, Name: raw}, nil
+		}
+		if named, ok := p.names[FullName(raw, namespace)]; ok {
+			return named, nil
+		}
+		if named, ok := p.names[raw]; ok {
+			return named, nil
+		}
+		return nil, fmt.Errorf("unknown avro type: %s", raw)
+	case []interface{}:
+		union := &Schema{Type: "union", Name: "union"}
+		for _, branch := range raw {
+			branchSchema, err := p.build(branch, namespace)
+			if err != nil {
+				return nil, err
+			}
+			union.Branches = append(union.Branches, branchSchema)
+		}
+		return union, nil
+	case map[string]interface{}:
+		typeName, _ := raw["type"].(string)
+		logicalType, _ := raw["logicalType"].(string)
+		doc, _ := raw["doc"].(string)
+		switch typeName {
+		case "record", "error", "enum", "fixed":
+			name, _ := raw["name"].(string)
+			if name == "" {
+				return nil, fmt.Errorf("%s without a name", typeName)
+			}
+			// the namespace attribute is ignored when the name is a full name
+			if ns, ok := raw["namespace"].(string); ok && !strings.Contains(name, ".") {
+				namespace = ns
+			}
+			s := &Schema{Type: typeName, Name: FullName(name, namespace), Doc: doc}
+			if typeName == "error" {
+				s.Type = "record"
+			}
+			if i := strings.LastIndex(s.Name, "."); i >= 0 {
+				namespace = s.Name[:i]
+			}
+			if _, ok := p.names[s.Name]; ok {
+				return nil, fmt.Errorf("avro type %s is declared twice", s.Name)
+			}
+			p.names[s.Name] = s
+			p.Named = append(p.Named, s)
+			switch typeName {
+			case "enum":
+				s.Symbols = stringList(raw["symbols"])
+			case "fixed":
+				size, _ := raw["size"].(float64)
+				s.Size = int(size)
+				if LogicalTypes[logicalType] {
+					s.LogicalType = logicalType
+				}
+			default:
+				fields, _ := raw["fields"].([]interface{})
+				for _, f := range fields {
+					rawField, _ := f.(map[string]interface{})
+					fieldName, _ := rawField["name"].(string)
+					fieldDoc, _ := rawField["doc"].(string)
+					fieldType, err := p.build(rawField["type"], namespace)
+					if err != nil {
+						return nil, fmt.Errorf("%s.%s: %v", s.Name, fieldName, err)
+					}
+					_, hasDefault := rawField["default"]
+					s.Fields = append(s.Fields, &Field{fieldName, fieldDoc, fieldType, stringList(rawField["aliases"]), hasDefault})
+				}
+			}
+			return s, nil
+		case "array":
+			items, err := p.build(raw["items"], namespace)
+			if err != nil {
+				return nil, err
+			}
+			return &Schema{Type: "array", Name: "array", Items: items}, nil
+		case "map":
+			values, err := p.build(raw["values"], namespace)
+			if err != nil {
+				return nil, err
+			}
+			return &Schema{Type: "map", Name: "map", Values: values}, nil
+		}
+		s, err := p.build(raw["type"], namespace)
+		if err != nil || !LogicalTypes[logicalType] {
+			return s, err
+		}
+		// goavro names logical types <type>.<logicalType>, which is also the key of their union branch
+		return &Schema{Type: s.Type, Name: s.Type + "." + logicalType, LogicalType: logicalType}, nil
+	}
+	return nil, fmt.Errorf("invalid avro schema: %v", raw)
+}
+
+// FullName returns the full name of name declared in namespace
+func FullName(name string, namespace string) string {
+	if namespace == "" || strings.Contains(name, ".") {
+		return name
+	}
+	return namespace + "." + name
+}
+
+// stringList returns the strings of a json array
+func stringList(raw interface{}) []string {
+	values, _ := raw.([]interface{})
+	var list []string
+	for _, value := range values {
+		if value, ok := value.(string); ok {
+			list = append(list, value)
+		}
+	}
+	return list
+}
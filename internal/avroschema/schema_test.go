@@ -0,0 +1,62 @@
+package avroschema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	schema, err := Parse([]byte(`{"type": "record", "name": "Order", "namespace": "com.example", "fields": [
+		{"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["NEW", "PAID"]}},
+		{"name": "other", "type": {"type": "enum", "name": "org.other.Status", "namespace": "ignored", "symbols": []}},
+		{"name": "previous", "type": ["null", "Status"], "default": null, "aliases": ["last"]},
+		{"name": "created_at", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+		{"name": "id", "type": {"type": "string", "logicalType": "uuid"}},
+		{"name": "hash", "type": {"type": "fixed", "name": "Hash", "size": 16}}
+	]}`))
+	if err != nil {
+		t.Fatalf("Error parsing schema: %v", err)
+	}
+	fields := schema.Fields
+	if schema.Name != "com.example.Order" || fields[0].Type.Name != "com.example.Status" || fields[1].Type.Name != "org.other.Status" {
+		t.Errorf("Full names do not match, got %s, %s and %s", schema.Name, fields[0].Type.Name, fields[1].Type.Name)
+	}
+	if !reflect.DeepEqual(fields[0].Type.Symbols, []string{"NEW", "PAID"}) {
+		t.Errorf("Symbols do not match, got %v", fields[0].Type.Symbols)
+	}
+	if fields[2].Type.Branches[1] != fields[0].Type || !fields[2].HasDefault || fields[0].HasDefault {
+		t.Errorf("Expected the union to reference the declared enum and only it to have a default")
+	}
+	if !reflect.DeepEqual(fields[2].Aliases, []string{"last"}) {
+		t.Errorf("Aliases do not match, got %v", fields[2].Aliases)
+	}
+	if fields[3].Type.Name != "long.timestamp-millis" || fields[3].Type.LogicalType != "timestamp-millis" {
+		t.Errorf("Expected the goavro name of the logical type, got %s", fields[3].Type.Name)
+	}
+	if fields[4].Type.Name != "string" || fields[4].Type.LogicalType != "" {
+		t.Errorf("Expected a logical type goavro does not convert to be its underlying type, got %s", fields[4].Type.Name)
+	}
+	if fields[5].Type.Size != 16 {
+		t.Errorf("Expected fixed size 16, got %d", fields[5].Type.Size)
+	}
+}
+
+func TestParser_References(t *testing.T) {
+	parser := NewParser()
+	if _, err := parser.Parse([]byte(`{"type": "record", "name": "Customer", "namespace": "com.example", "fields": []}`)); err != nil {
+		t.Fatalf("Error parsing schema: %v", err)
+	}
+	order, err := parser.Parse([]byte(`{"type": "record", "name": "Order", "namespace": "com.example", "fields": [{"name": "customer", "type": "Customer"}]}`))
+	if err != nil {
+		t.Fatalf("Error parsing schema referencing an earlier one: %v", err)
+	}
+	if order.Fields[0].Type != parser.Named[0] || len(parser.Named) != 2 {
+		t.Errorf("Expected the named types in declaration order to be shared")
+	}
+	if _, err := parser.Parse([]byte(`{"type": "record", "name": "com.example.Customer", "fields": []}`)); err == nil {
+		t.Errorf("Expected error declaring a type twice")
+	}
+	if _, err := Parse([]byte(`{"type": "record", "name": "Order", "fields": [{"name": "customer", "type": "Customer"}]}`)); err == nil {
+		t.Errorf("Expected error for an unknown type")
+	}
+}
@@ -0,0 +1,339 @@
+package kafka
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/linkedin/goavro"
+)
+
+// MockSchemaRegistryClient is an in memory SchemaRegistryClientInterface for tests and offline use. It assigns ids
+// and versions, checks the compatibility of new versions and soft deletes as schema registry does.
+type MockSchemaRegistryClient struct {
+	lock   sync.Mutex
+	lastId int
+	// schemas holds the codecs by id, ids stay valid after their subjects are deleted
+	schemas map[int]*goavro.Codec
+	// ids holds the ids by compacted schema, a schema registered to several subjects keeps its id
+	ids      map[string]int
+	subjects map[string]*mockSubject
+	// compatibility is the default CompatibilityLevel, subjects may override it
+	compatibility CompatibilityLevel
+	errors        map[string]error
+}
+
+type mockSubject struct {
+	versions      []mockVersion
+	lastVersion   int
+	compatibility CompatibilityLevel
+}
+
+type mockVersion struct {
+	version int
+	id      int
+}
+
+// NewMockSchemaRegistryClient creates an empty registry checking the BACKWARD compatibility of new versions
+func NewMockSchemaRegistryClient() *MockSchemaRegistryClient {
+	return &MockSchemaRegistryClient{
+		schemas:       map[int]*goavro.Codec{},
+		ids:           map[string]int{},
+		subjects:      map[string]*mockSubject{},
+		compatibility: CompatibilityBackward,
+		errors:        map[string]error{},
+	}
+}
+
+// SetCompatibility sets the CompatibilityLevel of a subject, or the default level when subject is empty
+func (client *MockSchemaRegistryClient) SetCompatibility(subject string, level CompatibilityLevel) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if subject == "" {
+		client.compatibility = level
+		return
+	}
+	client.subject(subject).compatibility = level
+}
+
+// InjectError makes every call of method fail with err until it is injected again with a nil err. The method
+// is named without the Context suffix, e.g. "GetSchema", an empty method fails every method.
+func (client *MockSchemaRegistryClient) InjectError(method string, err error) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if err == nil {
+		delete(client.errors, method)
+		return
+	}
+	client.errors[method] = err
+}
+
+// GetSchema returns the codec with the given id
+func (client *MockSchemaRegistryClient) GetSchema(id int) (*goavro.Codec, error) {
+	return client.GetSchemaContext(context.Background(), id)
+}
+
+// GetSchemaContext returns the codec with the given id
+func (client *MockSchemaRegistryClient) GetSchemaContext(ctx context.Context, id int) (*goavro.Codec, error) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if err := client.fail(ctx, "GetSchema"); err != nil {
+		return nil, err
+	}
+	codec, ok := client.schemas[id]
+	if !ok {
+		return nil, errSchemaNotFound
+	}
+	return codec, nil
+}
+
+// GetSubjects returns the subjects with at least one version, sorted by name
+func (client *MockSchemaRegistryClient) GetSubjects() ([]string, error) {
+	return client.GetSubjectsContext(context.Background())
+}
+
+// GetSubjectsContext returns the subjects with at least one version, sorted by name
+func (client *MockSchemaRegistryClient) GetSubjectsContext(ctx context.Context) ([]string, error) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if err := client.fail(ctx, "GetSubjects"); err != nil {
+		return nil, err
+	}
+	subjects := []string{}
+	for name, subject := range client.subjects {
+		if len(subject.versions) > 0 {
+			subjects = append(subjects, name)
+		}
+	}
+	sort.Strings(subjects)
+	return subjects, nil
+}
+
+// GetVersions returns the versions of a subject
+func (client *MockSchemaRegistryClient) GetVersions(subject string) ([]int, error) {
+	return client.GetVersionsContext(context.Background(), subject)
+}
+
+// GetVersionsContext returns the versions of a subject
+func (client *MockSchemaRegistryClient) GetVersionsContext(ctx context.Context, subject string) ([]int, error) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if err := client.fail(ctx, "GetVersions"); err != nil {
+		return nil, err
+	}
+	versions, err := client.versions(subject)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]int, len(versions))
+	for i, version := range versions {
+		result[i] = version.version
+	}
+	return result, nil
+}
+
+// GetSchemaByVersion returns the codec for a version of a subject
+func (client *MockSchemaRegistryClient) GetSchemaByVersion(subject string, version int) (*goavro.Codec, error) {
+	return client.GetSchemaByVersionContext(context.Background(), subject, version)
+}
+
+// GetSchemaByVersionContext returns the codec for a version of a subject
+func (client *MockSchemaRegistryClient) GetSchemaByVersionContext(ctx context.Context, subject string, version int) (*goavro.Codec, error) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if err := client.fail(ctx, "GetSchemaByVersion"); err != nil {
+		return nil, err
+	}
+	versions, err := client.versions(subject)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		if v.version == version {
+			return client.schemas[v.id], nil
+		}
+	}
+	return nil, errVersionNotFound
+}
+
+// GetLatestSchema returns the codec for the latest version of a subject
+func (client *MockSchemaRegistryClient) GetLatestSchema(subject string) (*goavro.Codec, error) {
+	return client.GetLatestSchemaContext(context.Background(), subject)
+}
+
+// GetLatestSchemaContext returns the codec for the latest version of a subject
+func (client *MockSchemaRegistryClient) GetLatestSchemaContext(ctx context.Context, subject string) (*goavro.Codec, error) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if err := client.fail(ctx, "GetLatestSchema"); err != nil {
+		return nil, err
+	}
+	versions, err := client.versions(subject)
+	if err != nil {
+		return nil, err
+	}
+	return client.schemas[versions[len(versions)-1].id], nil
+}
+
+// CreateSubject registers the codec as a new version of the subject unless it is already registered to it,
+// it fails with a 409 Error when the codec is not compatible with the previous versions
+func (client *MockSchemaRegistryClient) CreateSubject(subject string, codec *goavro.Codec) (int, error) {
+	return client.CreateSubjectContext(context.Background(), subject, codec)
+}
+
+// CreateSubjectContext registers the codec as a new version of the subject unless it is already registered to it,
+// it fails with a 409 Error when the codec is not compatible with the previous versions
+func (client *MockSchemaRegistryClient) CreateSubjectContext(ctx context.Context, subject string, codec *goavro.Codec) (int, error) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if err := client.fail(ctx, "CreateSubject"); err != nil {
+		return 0, err
+	}
+	if id, ok := client.registered(subject, codec); ok {
+		return id, nil
+	}
+	registered := client.subject(subject)
+	level := registered.compatibility
+	if level == "" {
+		level = client.compatibility
+	}
+	previous := make([]string, len(registered.versions))
+	for i, version := range registered.versions {
+		previous[i] = client.schemas[version.id].Schema()
+	}
+	compatible, err := level.compatible(codec.Schema(), previous)
+	if err != nil {
+		return 0, &Error{42201, "Invalid schema " + err.Error()}
+	}
+	if !compatible {
+		return 0, &Error{http.StatusConflict, "Schema being registered is incompatible with an earlier schema"}
+	}
+	schema := compactSchema(codec)
+	id, ok := client.ids[schema]
+	if !ok {
+		client.lastId++
+		id = client.lastId
+		client.ids[schema] = id
+		client.schemas[id] = codec
+	}
+	registered.lastVersion++
+	registered.versions = append(registered.versions, mockVersion{registered.lastVersion, id})
+	return id, nil
+}
+
+// IsSchemaRegistered returns the id of the codec if it is registered to the subject
+func (client *MockSchemaRegistryClient) IsSchemaRegistered(subject string, codec *goavro.Codec) (int, error) {
+	return client.IsSchemaRegisteredContext(context.Background(), subject, codec)
+}
+
+// IsSchemaRegisteredContext returns the id of the codec if it is registered to the subject
+func (client *MockSchemaRegistryClient) IsSchemaRegisteredContext(ctx context.Context, subject string, codec *goavro.Codec) (int, error) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if err := client.fail(ctx, "IsSchemaRegistered"); err != nil {
+		return 0, err
+	}
+	if _, err := client.versions(subject); err != nil {
+		return 0, err
+	}
+	if id, ok := client.registered(subject, codec); ok {
+		return id, nil
+	}
+	return 0, errSchemaNotFound
+}
+
+// DeleteSubject deletes all versions of the subject, their ids stay valid
+func (client *MockSchemaRegistryClient) DeleteSubject(subject string) error {
+	return client.DeleteSubjectContext(context.Background(), subject)
+}
+
+// DeleteSubjectContext deletes all versions of the subject, their ids stay valid
+func (client *MockSchemaRegistryClient) DeleteSubjectContext(ctx context.Context, subject string) error {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if err := client.fail(ctx, "DeleteSubject"); err != nil {
+		return err
+	}
+	if _, err := client.versions(subject); err != nil {
+		return err
+	}
+	client.subjects[subject].versions = nil
+	return nil
+}
+
+// DeleteVersion deletes a version of the subject, its id stays valid and the version number is not reused
+func (client *MockSchemaRegistryClient) DeleteVersion(subject string, version int) error {
+	return client.DeleteVersionContext(context.Background(), subject, version)
+}
+
+// DeleteVersionContext deletes a version of the subject, its id stays valid and the version number is not reused
+func (client *MockSchemaRegistryClient) DeleteVersionContext(ctx context.Context, subject string, version int) error {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	if err := client.fail(ctx, "DeleteVersion"); err != nil {
+		return err
+	}
+	versions, err := client.versions(subject)
+	if err != nil {
+		return err
+	}
+	for i, v := range versions {
+		if v.version == version {
+			client.subjects[subject].versions = append(versions[:i:i], versions[i+1:]...)
+			return nil
+		}
+	}
+	return errVersionNotFound
+}
+
+var (
+	errSubjectNotFound = &Error{40401, "Subject not found"}
+	errVersionNotFound = &Error{40402, "Version not found"}
+	errSchemaNotFound  = &Error{40403, "Schema not found"}
+)
+
+// fail returns the error of a done ctx or the error injected for method
+func (client *MockSchemaRegistryClient) fail(ctx context.Context, method string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err, ok := client.errors[method]; ok {
+		return err
+	}
+	return client.errors[""]
+}
+
+// subject returns the subject, creating it without versions when it does not exist
+func (client *MockSchemaRegistryClient) subject(name string) *mockSubject {
+	subject, ok := client.subjects[name]
+	if !ok {
+		subject = &mockSubject{}
+		client.subjects[name] = subject
+	}
+	return subject
+}
+
+// versions returns the versions of the subject, or an error when it has none
+func (client *MockSchemaRegistryClient) versions(name string) ([]mockVersion, error) {
+	subject, ok := client.subjects[name]
+	if !ok || len(subject.versions) == 0 {
+		return nil, errSubjectNotFound
+	}
+	return subject.versions, nil
+}
+
+// registered returns the id of the codec when it is a version of the subject
+func (client *MockSchemaRegistryClient) registered(name string, codec *goavro.Codec) (int, bool) {
+	subject, ok := client.subjects[name]
+	if !ok {
+		return 0, false
+	}
+	schema := compactSchema(codec)
+	for _, version := range subject.versions {
+		if compactSchema(client.schemas[version.id]) == schema {
+			return version.id, true
+		}
+	}
+	return 0, false
+}
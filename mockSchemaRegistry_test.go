@@ -0,0 +1,126 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/linkedin/goavro"
+)
+
+func TestMockSchemaRegistryClient(t *testing.T) {
+	var _ SchemaRegistryClientInterface = &MockSchemaRegistryClient{}
+	client := NewMockSchemaRegistryClient()
+	v1, _ := goavro.NewCodec(`{"type": "record", "name": "test", "fields": [{"name": "val", "type": "int"}]}`)
+	v2, _ := goavro.NewCodec(`{"type": "record", "name": "test", "fields": [{"name": "val", "type": "int"}, {"name": "name", "type": "string", "default": ""}]}`)
+	incompatible, _ := goavro.NewCodec(`{"type": "record", "name": "test", "fields": [{"name": "val", "type": "string"}]}`)
+
+	id1, err := client.CreateSubject("test-value", v1)
+	if err != nil || id1 != 1 {
+		t.Errorf("Expected id 1, got %d, %v", id1, err)
+	}
+	if id, _ := client.CreateSubject("test-value", v1); id != id1 {
+		t.Errorf("Expected the registered id, got %d", id)
+	}
+	if id, _ := client.CreateSubject("other-value", v1); id != id1 {
+		t.Errorf("Expected the schema to keep its id under another subject, got %d", id)
+	}
+	id2, _ := client.CreateSubject("test-value", v2)
+	if id2 != 2 {
+		t.Errorf("Expected id 2, got %d", id2)
+	}
+	_, err = client.CreateSubject("test-value", incompatible)
+	if registryErr, ok := err.(*Error); !ok || registryErr.ErrorCode != 409 {
+		t.Errorf("Expected an incompatible schema error, got %v", err)
+	}
+	client.SetCompatibility("test-value", CompatibilityNone)
+	if _, err := client.CreateSubject("test-value", incompatible); err != nil {
+		t.Errorf("Expected the schema to be accepted without compatibility, got %v", err)
+	}
+
+	if versions, _ := client.GetVersions("test-value"); len(versions) != 3 || versions[2] != 3 {
+		t.Errorf("Expected versions 1 to 3, got %v", versions)
+	}
+	if codec, _ := client.GetSchemaByVersion("test-value", 2); codec != v2 {
+		t.Errorf("Expected version 2, got %v", codec)
+	}
+	if subjects, _ := client.GetSubjects(); len(subjects) != 2 || subjects[0] != "other-value" {
+		t.Errorf("Expected 2 subjects, got %v", subjects)
+	}
+	if id, _ := client.IsSchemaRegistered("test-value", v2); id != id2 {
+		t.Errorf("Expected the schema to be registered, got %d", id)
+	}
+
+	if err := client.DeleteVersion("test-value", 3); err != nil {
+		t.Errorf("Error delete version: %v", err)
+	}
+	if codec, _ := client.GetLatestSchema("test-value"); codec != v2 {
+		t.Errorf("Expected version 2 to be the latest, got %v", codec)
+	}
+	if err := client.DeleteSubject("other-value"); err != nil {
+		t.Errorf("Error delete subject: %v", err)
+	}
+	if _, err := client.GetVersions("other-value"); err != errSubjectNotFound {
+		t.Errorf("Expected the subject to be deleted, got %v", err)
+	}
+	if codec, _ := client.GetSchema(id1); codec != v1 {
+		t.Errorf("Expected the id to stay valid after deleting its subject")
+	}
+	if _, err := client.GetSchema(42); err != errSchemaNotFound {
+		t.Errorf("Expected schema not found, got %v", err)
+	}
+}
+
+func TestMockSchemaRegistryClient_Defaults(t *testing.T) {
+	client := NewMockSchemaRegistryClient()
+	v1, _ := goavro.NewCodec(`{"type": "record", "name": "test", "fields": [{"name": "val", "type": "int"}]}`)
+	v2, _ := goavro.NewCodec(`{"type": "record", "name": "test", "fields": [{"name": "val", "type": "int", "default": 0}]}`)
+	reformatted, _ := goavro.NewCodec(`{"type":"record","name":"test","fields":[{"name":"val","type":"int","default":0}]}`)
+
+	id1, _ := client.CreateSubject("test-value", v1)
+	// only a default is added, the canonical form is the same but the registry sees a new schema
+	id2, _ := client.CreateSubject("test-value", v2)
+	if id2 == id1 {
+		t.Errorf("Expected a new id for the added default, got %d", id2)
+	}
+	if versions, _ := client.GetVersions("test-value"); len(versions) != 2 {
+		t.Errorf("Expected versions 1 and 2, got %v", versions)
+	}
+	if id, _ := client.CreateSubject("test-value", reformatted); id != id2 {
+		t.Errorf("Expected whitespace changes to keep the id, got %d", id)
+	}
+	if id, err := client.IsSchemaRegistered("test-value", reformatted); err != nil || id != id2 {
+		t.Errorf("Expected the reformatted schema to be registered, got %d, %v", id, err)
+	}
+	if versions, _ := client.GetVersions("test-value"); len(versions) != 2 {
+		t.Errorf("Expected no version for whitespace changes, got %v", versions)
+	}
+}
+
+func TestMockSchemaRegistryClient_Errors(t *testing.T) {
+	client := NewMockSchemaRegistryClient()
+	codec, _ := goavro.NewCodec(`"string"`)
+	failure := errors.New("registry down")
+
+	client.InjectError("CreateSubject", failure)
+	if _, err := client.CreateSubject("test", codec); err != failure {
+		t.Errorf("Expected the injected error, got %v", err)
+	}
+	client.InjectError("CreateSubject", nil)
+	id, err := client.CreateSubject("test", codec)
+	if err != nil {
+		t.Errorf("Error creating subject: %v", err)
+	}
+
+	client.InjectError("", failure)
+	if _, err := client.GetSchema(id); err != failure {
+		t.Errorf("Expected the injected error, got %v", err)
+	}
+	client.InjectError("", nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.GetSchemaContext(ctx, id); err != context.Canceled {
+		t.Errorf("Expected the cancelled context error, got %v", err)
+	}
+}
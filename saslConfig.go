@@ -0,0 +1,88 @@
+package kafka
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+
+	"github.com/Shopify/sarama"
+	"github.com/xdg/scram"
+)
+
+type SASLConfig struct {
+	Username string
+	Password string
+	// TLSConfig is the TLS config of the kafka connection when the TLS setting of the producer or consumer is nil,
+	// it is also the TLS config of the schema registry connection when SchemaRegistryTLS is nil.
+	// Deprecated: TLS is configured independently of SASL by the TLS and SchemaRegistryTLS settings
+	TLSConfig *tls.Config
+	// DisableTLS sends SASL over a plaintext kafka connection when neither TLS nor TLSConfig is set,
+	// by default SASL enables TLS with the default config of sarama
+	DisableTLS bool
+	// Mechanism is the SASL mechanism, sarama.SASLTypePlaintext when empty. sarama.SASLTypeSCRAMSHA256 and
+	// sarama.SASLTypeSCRAMSHA512 authenticate with Username and Password, sarama.SASLTypeOAuth with TokenProvider
+	Mechanism sarama.SASLMechanism
+	// SCRAMClientGeneratorFunc creates the clients of the SCRAM mechanisms, clients built on github.com/xdg/scram when nil
+	SCRAMClientGeneratorFunc func() sarama.SCRAMClient
+	// TokenProvider provides the tokens of the OAUTHBEARER mechanism
+	TokenProvider sarama.AccessTokenProvider
+}
+
+// setBrokerSecurity applies the SASL and TLS settings of the kafka connection, shared by producers and consumers.
+// TLS is enabled when tlsConfig is set, the deprecated SASLConfig.TLSConfig is used when it is nil.
+// SASL enables TLS unless SASLConfig.DisableTLS is set, so credentials are not sent in plaintext.
+func setBrokerSecurity(config *sarama.Config, sasl *SASLConfig, tlsConfig *tls.Config) {
+	if tlsConfig == nil && sasl != nil {
+		tlsConfig = sasl.TLSConfig
+	}
+	if tlsConfig != nil || (sasl != nil && !sasl.DisableTLS) {
+		config.Net.TLS.Enable = true
+		config.Net.TLS.Config = tlsConfig
+	}
+	if sasl == nil {
+		return
+	}
+	config.Net.SASL.Enable = true
+	config.Net.SASL.User = sasl.Username
+	config.Net.SASL.Password = sasl.Password
+	config.Net.SASL.Handshake = true
+	config.Net.SASL.Mechanism = sasl.Mechanism
+	if config.Net.SASL.Mechanism == "" {
+		config.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+	}
+	config.Net.SASL.SCRAMClientGeneratorFunc = sasl.SCRAMClientGeneratorFunc
+	if config.Net.SASL.SCRAMClientGeneratorFunc == nil {
+		switch config.Net.SASL.Mechanism {
+		case sarama.SASLTypeSCRAMSHA256:
+			config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return &scramClient{HashGeneratorFcn: sha256.New} }
+		case sarama.SASLTypeSCRAMSHA512:
+			config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return &scramClient{HashGeneratorFcn: sha512.New} }
+		}
+	}
+	config.Net.SASL.TokenProvider = sasl.TokenProvider
+}
+
+// scramClient is a sarama.SCRAMClient built on github.com/xdg/scram
+type scramClient struct {
+	*scram.Client
+	*scram.ClientConversation
+	scram.HashGeneratorFcn
+}
+
+func (c *scramClient) Begin(userName, password, authzID string) error {
+	client, err := c.HashGeneratorFcn.NewClient(userName, password, authzID)
+	if err != nil {
+		return err
+	}
+	c.Client = client
+	c.ClientConversation = client.NewConversation()
+	return nil
+}
+
+func (c *scramClient) Step(challenge string) (string, error) {
+	return c.ClientConversation.Step(challenge)
+}
+
+func (c *scramClient) Done() bool {
+	return c.ClientConversation.Done()
+}
@@ -0,0 +1,127 @@
+package kafka
+
+import (
+	"crypto/sha512"
+	"crypto/tls"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/xdg/scram"
+)
+
+type testTokenProvider struct{}
+
+func (p testTokenProvider) Token() (*sarama.AccessToken, error) {
+	return &sarama.AccessToken{Token: "token"}, nil
+}
+
+func TestSetBrokerSecurity(t *testing.T) {
+	config := sarama.NewConfig()
+	setBrokerSecurity(config, nil, nil)
+	if config.Net.SASL.Enable || config.Net.TLS.Enable {
+		t.Errorf("Expected SASL and TLS to be disabled without a SASL config")
+	}
+
+	tlsConfig := &tls.Config{ServerName: "kafka"}
+	setBrokerSecurity(config, &SASLConfig{Username: "user", Password: "password", TLSConfig: tlsConfig}, nil)
+	if !config.Net.SASL.Enable || config.Net.SASL.User != "user" || config.Net.SASL.Password != "password" {
+		t.Errorf("Expected SASL to be enabled with the credentials, got %+v", config.Net.SASL)
+	}
+	if config.Net.SASL.Mechanism != sarama.SASLTypePlaintext {
+		t.Errorf("Expected the PLAIN mechanism, got %s", config.Net.SASL.Mechanism)
+	}
+	if !config.Net.TLS.Enable || config.Net.TLS.Config != tlsConfig {
+		t.Errorf("Expected TLS to be enabled with the TLS config of SASL")
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected a valid config, got %v", err)
+	}
+}
+
+func TestSetBrokerSecurity_TLS(t *testing.T) {
+	tlsConfig := &tls.Config{ServerName: "kafka"}
+	config := sarama.NewConfig()
+	setBrokerSecurity(config, nil, tlsConfig)
+	if config.Net.SASL.Enable || !config.Net.TLS.Enable || config.Net.TLS.Config != tlsConfig {
+		t.Errorf("Expected TLS without SASL")
+	}
+
+	config = sarama.NewConfig()
+	setBrokerSecurity(config, &SASLConfig{Username: "user", Password: "password"}, nil)
+	if !config.Net.SASL.Enable || !config.Net.TLS.Enable || config.Net.TLS.Config != nil {
+		t.Errorf("Expected SASL to enable TLS with the default config")
+	}
+
+	config = sarama.NewConfig()
+	setBrokerSecurity(config, &SASLConfig{Username: "user", Password: "password", DisableTLS: true}, nil)
+	if !config.Net.SASL.Enable || config.Net.TLS.Enable {
+		t.Errorf("Expected SASL without TLS")
+	}
+
+	config = sarama.NewConfig()
+	setBrokerSecurity(config, &SASLConfig{Username: "user", Password: "password", DisableTLS: true}, tlsConfig)
+	if !config.Net.TLS.Enable || config.Net.TLS.Config != tlsConfig {
+		t.Errorf("Expected the TLS setting to enable TLS")
+	}
+
+	config = sarama.NewConfig()
+	setBrokerSecurity(config, &SASLConfig{Username: "user", Password: "password", TLSConfig: &tls.Config{}}, tlsConfig)
+	if config.Net.TLS.Config != tlsConfig {
+		t.Errorf("Expected the TLS setting to take precedence over the TLS config of SASL")
+	}
+}
+
+func TestSetBrokerSecurity_Mechanisms(t *testing.T) {
+	for _, mechanism := range []sarama.SASLMechanism{sarama.SASLTypeSCRAMSHA256, sarama.SASLTypeSCRAMSHA512} {
+		config := sarama.NewConfig()
+		setBrokerSecurity(config, &SASLConfig{Username: "user", Password: "password", Mechanism: mechanism}, nil)
+		if config.Net.SASL.Mechanism != mechanism || config.Net.SASL.SCRAMClientGeneratorFunc == nil {
+			t.Errorf("Expected %s with a SCRAM client generator", mechanism)
+		}
+		if err := config.Validate(); err != nil {
+			t.Errorf("Expected a valid %s config, got %v", mechanism, err)
+		}
+	}
+
+	config := sarama.NewConfig()
+	setBrokerSecurity(config, &SASLConfig{Mechanism: sarama.SASLTypeOAuth, TokenProvider: testTokenProvider{}}, nil)
+	if config.Net.SASL.Mechanism != sarama.SASLTypeOAuth || config.Net.SASL.TokenProvider == nil {
+		t.Errorf("Expected OAUTHBEARER with the token provider")
+	}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected a valid OAUTHBEARER config, got %v", err)
+	}
+}
+
+func TestScramClient(t *testing.T) {
+	client := &scramClient{HashGeneratorFcn: sha512.New}
+	if err := client.Begin("user", "password", ""); err != nil {
+		t.Fatalf("Error beginning the conversation: %v", err)
+	}
+	credentials := client.Client.GetStoredCredentials(scram.KeyFactors{Salt: "salt", Iters: 4096})
+	server, err := scram.HashGeneratorFcn(sha512.New).NewServer(func(user string) (scram.StoredCredentials, error) {
+		return credentials, nil
+	})
+	if err != nil {
+		t.Fatalf("Error creating the server: %v", err)
+	}
+	conversation := server.NewConversation()
+
+	var challenge string
+	for !client.Done() {
+		response, err := client.Step(challenge)
+		if err != nil {
+			t.Fatalf("Error in client step: %v", err)
+		}
+		if response == "" && client.Done() {
+			break
+		}
+		challenge, err = conversation.Step(response)
+		if err != nil {
+			t.Fatalf("Error in server step: %v", err)
+		}
+	}
+	if !conversation.Valid() {
+		t.Errorf("Expected the server to authenticate the client")
+	}
+}
@@ -0,0 +1,104 @@
+package kafka
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// CacheConfig bounds the caches of a CachedSchemaRegistryClient
+type CacheConfig struct {
+	// MaxEntries is the number of entries of each cache, the least recently used are evicted first, no limit when 0
+	MaxEntries int
+	// TTL is how long mutable lookups, the latest schema and the versions of a subject, are cached.
+	// They are not cached when 0. Schemas by id or version and registered ids do not expire.
+	TTL time.Duration
+}
+
+// defaultCacheConfig is the CacheConfig of clients created without WithCacheConfig
+var defaultCacheConfig = CacheConfig{
+	MaxEntries: 1000,
+	TTL:        time.Minute,
+}
+
+// WithCacheConfig bounds the caches of a CachedSchemaRegistryClient, it has no effect on a SchemaRegistryClient
+func WithCacheConfig(cacheConfig CacheConfig) SchemaRegistryOption {
+	return func(options *schemaRegistryOptions) {
+		options.cacheConfig = cacheConfig
+	}
+}
+
+// lruCache is a cache evicting its least recently used entries, entries may expire
+type lruCache struct {
+	lock       sync.Mutex
+	maxEntries int
+	items      map[interface{}]*list.Element
+	order      *list.List
+}
+
+type cacheEntry struct {
+	key     interface{}
+	value   interface{}
+	expires time.Time
+}
+
+func newLRUCache(maxEntries int) *lruCache {
+	return &lruCache{maxEntries: maxEntries, items: map[interface{}]*list.Element{}, order: list.New()}
+}
+
+func (c *lruCache) get(key interface{}) (interface{}, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	element, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.removeElement(element)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return entry.value, true
+}
+
+// set caches value, it expires after ttl unless ttl is 0
+func (c *lruCache) set(key interface{}, value interface{}, ttl time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+	if element, ok := c.items[key]; ok {
+		element.Value = &cacheEntry{key, value, expires}
+		c.order.MoveToFront(element)
+		return
+	}
+	c.items[key] = c.order.PushFront(&cacheEntry{key, value, expires})
+	if c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		c.removeElement(c.order.Back())
+	}
+}
+
+// removeIf removes the entries whose key matches
+func (c *lruCache) removeIf(match func(key interface{}) bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for key, element := range c.items {
+		if match(key) {
+			c.removeElement(element)
+		}
+	}
+}
+
+func (c *lruCache) len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.order.Len()
+}
+
+func (c *lruCache) removeElement(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.items, element.Value.(*cacheEntry).key)
+}
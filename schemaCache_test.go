@@ -0,0 +1,37 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUCache(t *testing.T) {
+	cache := newLRUCache(2)
+	cache.set(1, "a", 0)
+	cache.set(2, "b", 0)
+	cache.get(1)
+	cache.set(3, "c", 0)
+	if _, found := cache.get(2); found {
+		t.Errorf("Expected the least recently used entry to be evicted")
+	}
+	if value, found := cache.get(1); !found || value != "a" {
+		t.Errorf("Expected the recently used entry to be kept, got %v", value)
+	}
+	if cache.len() != 2 {
+		t.Errorf("Expected 2 entries, got %d", cache.len())
+	}
+
+	cache.set(3, "d", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, found := cache.get(3); found {
+		t.Errorf("Expected the entry to expire")
+	}
+	if cache.len() != 1 {
+		t.Errorf("Expected the expired entry to be removed, got %d entries", cache.len())
+	}
+
+	cache.removeIf(func(key interface{}) bool { return key == 1 })
+	if cache.len() != 0 {
+		t.Errorf("Expected the matching entry to be removed, got %d entries", cache.len())
+	}
+}
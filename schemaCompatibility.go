@@ -0,0 +1,161 @@
+package kafka
+
+import (
+	"strings"
+
+	"github.com/ihsanul14/go-confluent-kafka/internal/avroschema"
+)
+
+// CompatibilityLevel decides which schemas may be registered as a new version of a subject, as in schema registry
+type CompatibilityLevel string
+
+const (
+	// CompatibilityNone accepts every schema
+	CompatibilityNone CompatibilityLevel = "NONE"
+	// CompatibilityBackward accepts schemas able to read data written with the latest version
+	CompatibilityBackward CompatibilityLevel = "BACKWARD"
+	// CompatibilityBackwardTransitive accepts schemas able to read data written with every version
+	CompatibilityBackwardTransitive CompatibilityLevel = "BACKWARD_TRANSITIVE"
+	// CompatibilityForward accepts schemas whose data the latest version is able to read
+	CompatibilityForward CompatibilityLevel = "FORWARD"
+	// CompatibilityForwardTransitive accepts schemas whose data every version is able to read
+	CompatibilityForwardTransitive CompatibilityLevel = "FORWARD_TRANSITIVE"
+	// CompatibilityFull accepts schemas both backward and forward compatible with the latest version
+	CompatibilityFull CompatibilityLevel = "FULL"
+	// CompatibilityFullTransitive accepts schemas both backward and forward compatible with every version
+	CompatibilityFullTransitive CompatibilityLevel = "FULL_TRANSITIVE"
+)
+
+// compatible tests if schema may be registered after the previous schemas of a subject, oldest first
+func (level CompatibilityLevel) compatible(schema string, previous []string) (bool, error) {
+	if level == CompatibilityNone || len(previous) == 0 {
+		return true, nil
+	}
+	if !strings.HasSuffix(string(level), "_TRANSITIVE") {
+		previous = previous[len(previous)-1:]
+	}
+	reader, err := parseSchemaJson(schema)
+	if err != nil {
+		return false, err
+	}
+	backward := strings.HasPrefix(string(level), "BACKWARD") || strings.HasPrefix(string(level), "FULL")
+	forward := strings.HasPrefix(string(level), "FORWARD") || strings.HasPrefix(string(level), "FULL")
+	for _, old := range previous {
+		writer, err := parseSchemaJson(old)
+		if err != nil {
+			return false, err
+		}
+		if backward && !canRead(reader, writer, map[[2]*avroschema.Schema]bool{}) {
+			return false, nil
+		}
+		if forward && !canRead(writer, reader, map[[2]*avroschema.Schema]bool{}) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// canRead tests if data written with writer can be read with reader following the avro schema resolution rules,
+// seen holds the pairs of named types being compared to end recursion
+func canRead(reader, writer *avroschema.Schema, seen map[[2]*avroschema.Schema]bool) bool {
+	if writer.Type == "union" {
+		for _, branch := range writer.Branches {
+			if !canRead(reader, branch, seen) {
+				return false
+			}
+		}
+		return true
+	}
+	if reader.Type == "union" {
+		for _, branch := range reader.Branches {
+			if canRead(branch, writer, seen) {
+				return true
+			}
+		}
+		return false
+	}
+	if reader.Type != writer.Type {
+		return promotable(writer.Type, reader.Type)
+	}
+	switch reader.Type {
+	case "array":
+		return canRead(reader.Items, writer.Items, seen)
+	case "map":
+		return canRead(reader.Values, writer.Values, seen)
+	case "fixed":
+		return shortName(reader) == shortName(writer) && reader.Size == writer.Size
+	case "enum":
+		if shortName(reader) != shortName(writer) {
+			return false
+		}
+		for _, symbol := range writer.Symbols {
+			if !hasString(reader.Symbols, symbol) {
+				return false
+			}
+		}
+		return true
+	case "record":
+		if shortName(reader) != shortName(writer) {
+			return false
+		}
+		pair := [2]*avroschema.Schema{reader, writer}
+		if seen[pair] {
+			return true
+		}
+		seen[pair] = true
+		for _, field := range reader.Fields {
+			written := writerField(writer, field)
+			if written == nil {
+				if !field.HasDefault {
+					return false
+				}
+				continue
+			}
+			if !canRead(field.Type, written.Type, seen) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// shortName returns the unqualified name of a named type, the schema resolution rules ignore namespaces
+func shortName(schema *avroschema.Schema) string {
+	return schema.Name[strings.LastIndex(schema.Name, ".")+1:]
+}
+
+// writerField returns the field of the writer record matching the name or an alias of the reader field
+func writerField(writer *avroschema.Schema, reader *avroschema.Field) *avroschema.Field {
+	for _, field := range writer.Fields {
+		if field.Name == reader.Name || hasString(reader.Aliases, field.Name) {
+			return field
+		}
+	}
+	return nil
+}
+
+// promotable tests if a value written as writer can be read as reader
+func promotable(writer, reader string) bool {
+	switch writer {
+	case "int":
+		return reader == "long" || reader == "float" || reader == "double"
+	case "long":
+		return reader == "float" || reader == "double"
+	case "float":
+		return reader == "double"
+	case "string":
+		return reader == "bytes"
+	case "bytes":
+		return reader == "string"
+	}
+	return false
+}
+
+func hasString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package kafka
+
+import "testing"
+
+func TestCompatibilityLevel(t *testing.T) {
+	v1 := `{"type":"record","name":"user","fields":[{"name":"id","type":"int"}]}`
+	addedWithDefault := `{"type":"record","name":"user","fields":[{"name":"id","type":"long"},{"name":"name","type":"string","default":""}]}`
+	addedWithoutDefault := `{"type":"record","name":"user","fields":[{"name":"id","type":"int"},{"name":"name","type":"string"}]}`
+	removed := `{"type":"record","name":"user","fields":[]}`
+	renamed := `{"type":"record","name":"user","fields":[{"name":"key","aliases":["id"],"type":"int"}]}`
+	recursive := `{"type":"record","name":"node","namespace":"test","fields":[{"name":"next","type":["null","test.node"]}]}`
+	recursiveOptional := `{"type":"record","name":"node","namespace":"test","fields":[{"name":"next","type":["null","node"]},{"name":"v","type":["null","int"],"default":null}]}`
+	enum := `{"type":"enum","name":"color","symbols":["RED","GREEN"]}`
+	enumExtended := `{"type":"enum","name":"color","symbols":["RED","GREEN","BLUE"]}`
+
+	tests := []struct {
+		level      CompatibilityLevel
+		schema     string
+		previous   []string
+		compatible bool
+	}{
+		{CompatibilityBackward, addedWithDefault, []string{v1}, true},
+		{CompatibilityBackward, addedWithoutDefault, []string{v1}, false},
+		{CompatibilityBackward, removed, []string{v1}, true},
+		{CompatibilityBackward, renamed, []string{v1}, true},
+		{CompatibilityBackward, v1, []string{addedWithDefault}, false},
+		{CompatibilityForward, removed, []string{v1}, false},
+		{CompatibilityForward, addedWithoutDefault, []string{v1}, true},
+		{CompatibilityFull, addedWithDefault, []string{v1}, false},
+		{CompatibilityFull, recursiveOptional, []string{recursive}, true},
+		{CompatibilityBackward, enumExtended, []string{enum}, true},
+		{CompatibilityForward, enumExtended, []string{enum}, false},
+		{CompatibilityBackward, `"long"`, []string{`"int"`}, true},
+		{CompatibilityBackward, `"int"`, []string{`"long"`}, false},
+		{CompatibilityBackward, `["null","string"]`, []string{`"string"`}, true},
+		{CompatibilityBackward, `"string"`, []string{`["null","string"]`}, false},
+		{CompatibilityBackward, removed, []string{addedWithoutDefault, v1}, true},
+		{CompatibilityBackwardTransitive, addedWithoutDefault, []string{removed, addedWithoutDefault}, false},
+		{CompatibilityNone, `"int"`, []string{`"string"`}, true},
+		{CompatibilityBackward, `{"type":"long","logicalType":"timestamp-millis"}`, []string{`"long"`}, true},
+		{CompatibilityBackward, `{"type":"int","logicalType":"date"}`, []string{`"long"`}, false},
+		{CompatibilityFull, `{"type":"enum","name":"com.example.color","symbols":["RED","GREEN"]}`, []string{enum}, true},
+	}
+	for i, test := range tests {
+		compatible, err := test.level.compatible(test.schema, test.previous)
+		if err != nil {
+			t.Errorf("%d: error checking compatibility: %v", i, err)
+		}
+		if compatible != test.compatible {
+			t.Errorf("%d: expected %s compatible %v, got %v", i, test.level, test.compatible, compatible)
+		}
+	}
+}
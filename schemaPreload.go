@@ -0,0 +1,152 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/linkedin/goavro"
+)
+
+// PreloadRequest lists the schemas Preload fetches into the caches of a CachedSchemaRegistryClient
+type PreloadRequest struct {
+	// Subjects are preloaded with their latest version, or with all their versions when AllVersions is set
+	Subjects    []string
+	AllVersions bool
+	// Ids are the ids of schemas to preload
+	Ids []int
+	// Concurrency is the number of parallel registry requests, 8 when 0
+	Concurrency int
+}
+
+// PreloadFailure is a schema Preload could not fetch, Version is 0 for the latest version or the versions of Subject
+type PreloadFailure struct {
+	Subject string
+	Version int
+	Id      int
+	Err     error
+}
+
+// PreloadError is returned by Preload when some schemas could not be fetched
+type PreloadError struct {
+	Failures []PreloadFailure
+}
+
+func (e *PreloadError) Error() string {
+	failures := make([]string, len(e.Failures))
+	for i, failure := range e.Failures {
+		switch {
+		case failure.Subject == "":
+			failures[i] = fmt.Sprintf("schema %d: %v", failure.Id, failure.Err)
+		case failure.Version == 0:
+			failures[i] = fmt.Sprintf("subject %s: %v", failure.Subject, failure.Err)
+		default:
+			failures[i] = fmt.Sprintf("subject %s version %d: %v", failure.Subject, failure.Version, failure.Err)
+		}
+	}
+	return fmt.Sprintf("preloading %d schemas failed: %s", len(e.Failures), strings.Join(failures, ", "))
+}
+
+// Preload fetches the schemas of the request in parallel and caches them by id, by subject version and by subject
+// and schema, so they are not looked up when the first messages are produced or consumed. It returns a *PreloadError
+// listing the schemas that could not be fetched.
+func (client *CachedSchemaRegistryClient) Preload(ctx context.Context, request PreloadRequest) error {
+	concurrency := request.Concurrency
+	if concurrency <= 0 {
+		concurrency = 8
+	}
+	var lock sync.Mutex
+	var failures []PreloadFailure
+	fail := func(failure PreloadFailure) {
+		lock.Lock()
+		failures = append(failures, failure)
+		lock.Unlock()
+	}
+
+	// versions are the subject versions to fetch, version 0 is the latest
+	var versions []subjectVersionKey
+	if request.AllVersions {
+		parallel(concurrency, len(request.Subjects), func(i int) {
+			subject := request.Subjects[i]
+			subjectVersions, err := client.GetVersionsContext(ctx, subject)
+			if err != nil {
+				fail(PreloadFailure{Subject: subject, Err: err})
+				return
+			}
+			lock.Lock()
+			for _, version := range subjectVersions {
+				versions = append(versions, subjectVersionKey{subject, version})
+			}
+			lock.Unlock()
+		})
+	} else {
+		for _, subject := range request.Subjects {
+			versions = append(versions, subjectVersionKey{subject, 0})
+		}
+	}
+
+	parallel(concurrency, len(versions)+len(request.Ids), func(i int) {
+		if i >= len(versions) {
+			id := request.Ids[i-len(versions)]
+			if _, err := client.GetSchemaContext(ctx, id); err != nil {
+				fail(PreloadFailure{Id: id, Err: err})
+			}
+			return
+		}
+		version := versions[i]
+		if err := client.preloadVersion(ctx, version.subject, version.version); err != nil {
+			fail(PreloadFailure{Subject: version.subject, Version: version.version, Err: err})
+		}
+	})
+
+	if len(failures) > 0 {
+		return &PreloadError{failures}
+	}
+	return nil
+}
+
+// preloadVersion fetches a version of a subject, the latest when version is 0, and caches its schema
+func (client *CachedSchemaRegistryClient) preloadVersion(ctx context.Context, subject string, version int) error {
+	requested := latestVersion
+	if version > 0 {
+		requested = strconv.Itoa(version)
+	}
+	response, err := client.SchemaRegistryClient.getSubjectVersion(ctx, subject, requested)
+	if err != nil {
+		return err
+	}
+	codec, err := goavro.NewCodec(response.Schema)
+	if err != nil {
+		return err
+	}
+	client.schemaCache.set(response.ID, codec, 0)
+	client.schemaIdCache.set(subjectSchemaKey{subject, compactSchema(codec)}, response.ID, 0)
+	client.subjectCache.set(subjectVersionKey{subject, response.Version}, codec, 0)
+	if version == 0 && client.cacheConfig.TTL > 0 {
+		client.subjectCache.set(latestSchemaKey(subject), codec, client.cacheConfig.TTL)
+	}
+	client.save(response.ID, subject, codec)
+	return nil
+}
+
+// parallel calls fn for 0 to n-1 with at most concurrency calls at a time
+func parallel(concurrency int, n int, fn func(i int)) {
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrency && worker < n; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
@@ -0,0 +1,49 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCachedSchemaRegistryClient_Preload(t *testing.T) {
+	testObject := createSchemaRegistryTestObject(t, "test", 1)
+	defer testObject.MockServer.Close()
+	client := NewCachedSchemaRegistryClient([]string{testObject.MockServer.URL}, nil)
+
+	err := client.Preload(context.Background(), PreloadRequest{Subjects: []string{"test", "missing"}, AllVersions: true, Ids: []int{2}})
+	preloadErr, ok := err.(*PreloadError)
+	if !ok || len(preloadErr.Failures) != 2 {
+		t.Fatalf("Expected 2 failures, got %v", err)
+	}
+	for _, failure := range preloadErr.Failures {
+		if failure.Subject != "missing" && failure.Id != 2 {
+			t.Errorf("Unexpected failure %v", failure)
+		}
+	}
+
+	count := testObject.Count
+	client.GetSchema(1)
+	client.GetSchemaByVersion("test", 1)
+	client.CreateSubject("test", testObject.Codec)
+	if testObject.Count != count {
+		t.Errorf("Expected the preloaded schemas to be cached, got %d requests", testObject.Count-count)
+	}
+}
+
+func TestCachedSchemaRegistryClient_PreloadLatest(t *testing.T) {
+	testObject := createSchemaRegistryTestObject(t, "test", 1)
+	defer testObject.MockServer.Close()
+	client := NewCachedSchemaRegistryClient([]string{testObject.MockServer.URL}, nil)
+
+	if err := client.Preload(context.Background(), PreloadRequest{Subjects: []string{"test"}, Ids: []int{1}}); err != nil {
+		t.Errorf("Error preloading: %v", err)
+	}
+	if testObject.Count != 2 {
+		t.Errorf("Expected 2 requests, got %d", testObject.Count)
+	}
+	client.GetLatestSchema("test")
+	client.GetSchema(1)
+	if testObject.Count != 2 {
+		t.Errorf("Expected the preloaded schemas to be cached, got %d requests", testObject.Count)
+	}
+}
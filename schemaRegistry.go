@@ -2,13 +2,12 @@ package kafka
 
 import (
 	"bytes"
-	"crypto/rand"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"math/big"
 	"net/http"
 	"time"
 
@@ -16,6 +15,7 @@ import (
 )
 
 // SchemaRegistryClientInterface defines the api for all clients interfacing with schema registry
+// The Context variants bind the requests to the context, the others use context.Background()
 type SchemaRegistryClientInterface interface {
 	GetSchema(int) (*goavro.Codec, error)
 	GetSubjects() ([]string, error)
@@ -26,6 +26,15 @@ type SchemaRegistryClientInterface interface {
 	IsSchemaRegistered(string, *goavro.Codec) (int, error)
 	DeleteSubject(string) error
 	DeleteVersion(string, int) error
+	GetSchemaContext(context.Context, int) (*goavro.Codec, error)
+	GetSubjectsContext(context.Context) ([]string, error)
+	GetVersionsContext(context.Context, string) ([]int, error)
+	GetSchemaByVersionContext(context.Context, string, int) (*goavro.Codec, error)
+	GetLatestSchemaContext(context.Context, string) (*goavro.Codec, error)
+	CreateSubjectContext(context.Context, string, *goavro.Codec) (int, error)
+	IsSchemaRegisteredContext(context.Context, string, *goavro.Codec) (int, error)
+	DeleteSubjectContext(context.Context, string) error
+	DeleteVersionContext(context.Context, string, int) error
 }
 
 // SchemaRegistryClient is a basic http client to interact with schema registry
@@ -34,6 +43,12 @@ type SchemaRegistryClient struct {
 	httpClient            *http.Client
 	retries               int
 	SASL                  *SASLConfig
+	// SubjectNameStrategy maps topics to subjects, TopicNameStrategy is used when nil
+	SubjectNameStrategy SubjectNameStrategy
+	// Auth authenticates the requests, the username and password of SASL are sent as basic auth when nil
+	Auth        SchemaRegistryAuth
+	retryPolicy RetryPolicy
+	endpoints   *endpoints
 }
 
 type schemaResponse struct {
@@ -54,9 +69,9 @@ type idResponse struct {
 const (
 	schemaByID       = "/schemas/ids/%d"
 	subjects         = "/subjects"
-	subjectVersions  = "/subjects/%s-value/versions"
-	deleteSubject    = "/subjects/%s-value"
-	subjectByVersion = "/subjects/%s-value/versions/%s"
+	subjectVersions  = "/subjects/%s/versions"
+	deleteSubject    = "/subjects/%s"
+	subjectByVersion = "/subjects/%s/versions/%s"
 
 	latestVersion = "latest"
 
@@ -66,29 +81,53 @@ const (
 )
 
 // NewSchemaRegistryClient creates a client to talk with the schema registry at the connect string
-// By default it will retry failed requests (5XX responses and http errors) len(connect) number of times
+// By default it will retry failed requests (5XX responses and http errors) len(connect) number of times.
+// Certificates are verified against saslConfig.TLSConfig when it is set, against the system roots otherwise
 func NewSchemaRegistryClient(connect []string, saslConfig *SASLConfig) *SchemaRegistryClient {
-	tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
-	client := &http.Client{
-		Timeout:   timeout,
-		Transport: tr,
-	}
-	return &SchemaRegistryClient{connect, client, len(connect), saslConfig}
+	return NewSchemaRegistryClientWithOptions(connect, WithSASL(saslConfig))
 }
 
 // NewSchemaRegistryClientWithRetries creates an http client with a configurable amount of retries on 5XX responses
 func NewSchemaRegistryClientWithRetries(connect []string, retries int, saslConfig *SASLConfig) *SchemaRegistryClient {
-	tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
-	client := &http.Client{
-		Timeout:   timeout,
-		Transport: tr,
+	return NewSchemaRegistryClientWithOptions(connect, WithRetries(retries), WithSASL(saslConfig))
+}
+
+// NewSchemaRegistryClientWithTLS creates a client connecting with tlsConfig, which holds the CA bundle, the client
+// certificates for mTLS and the server name. Certificates are always verified unless tlsConfig sets InsecureSkipVerify.
+// saslConfig.TLSConfig is used when tlsConfig is nil
+func NewSchemaRegistryClientWithTLS(connect []string, tlsConfig *tls.Config, saslConfig *SASLConfig) *SchemaRegistryClient {
+	return NewSchemaRegistryClientWithOptions(connect, WithTLSConfig(tlsConfig), WithSASL(saslConfig))
+}
+
+// registryTLSConfig returns the TLS config of the registry connection, nil for the default verifying config
+func registryTLSConfig(tlsConfig *tls.Config, saslConfig *SASLConfig) *tls.Config {
+	if tlsConfig == nil && saslConfig != nil {
+		tlsConfig = saslConfig.TLSConfig
 	}
-	return &SchemaRegistryClient{connect, client, retries, saslConfig}
+	return tlsConfig
+}
+
+// EndpointStatus returns the health of every server of SchemaRegistryConnect, e.g. for health checks
+func (client *SchemaRegistryClient) EndpointStatus() []EndpointStatus {
+	return client.endpoints.status()
+}
+
+// SubjectName returns the subject for the key or value schema of a topic using the configured SubjectNameStrategy
+func (client *SchemaRegistryClient) SubjectName(topic string, isKey bool, codec *goavro.Codec) (string, error) {
+	if client.SubjectNameStrategy == nil {
+		return TopicNameStrategy(topic, isKey, codec)
+	}
+	return client.SubjectNameStrategy(topic, isKey, codec)
 }
 
 // GetSchema returns a goavro.Codec by unique id
 func (client *SchemaRegistryClient) GetSchema(id int) (*goavro.Codec, error) {
-	resp, err := client.httpCall("GET", fmt.Sprintf(schemaByID, id), nil)
+	return client.GetSchemaContext(context.Background(), id)
+}
+
+// GetSchemaContext returns a goavro.Codec by unique id
+func (client *SchemaRegistryClient) GetSchemaContext(ctx context.Context, id int) (*goavro.Codec, error) {
+	resp, err := client.httpCall(ctx, "GET", fmt.Sprintf(schemaByID, id), nil)
 	if nil != err {
 		return nil, err
 	}
@@ -101,7 +140,12 @@ func (client *SchemaRegistryClient) GetSchema(id int) (*goavro.Codec, error) {
 
 // GetSubjects returns a list of all subjects in the schema registry
 func (client *SchemaRegistryClient) GetSubjects() ([]string, error) {
-	resp, err := client.httpCall("GET", subjects, nil)
+	return client.GetSubjectsContext(context.Background())
+}
+
+// GetSubjectsContext returns a list of all subjects in the schema registry
+func (client *SchemaRegistryClient) GetSubjectsContext(ctx context.Context) ([]string, error) {
+	resp, err := client.httpCall(ctx, "GET", subjects, nil)
 	if nil != err {
 		return []string{}, err
 	}
@@ -112,7 +156,12 @@ func (client *SchemaRegistryClient) GetSubjects() ([]string, error) {
 
 // GetVersions returns a list of the versions of a subject
 func (client *SchemaRegistryClient) GetVersions(subject string) ([]int, error) {
-	resp, err := client.httpCall("GET", fmt.Sprintf(subjectVersions, subject), nil)
+	return client.GetVersionsContext(context.Background(), subject)
+}
+
+// GetVersionsContext returns a list of the versions of a subject
+func (client *SchemaRegistryClient) GetVersionsContext(ctx context.Context, subject string) ([]int, error) {
+	resp, err := client.httpCall(ctx, "GET", fmt.Sprintf(subjectVersions, subject), nil)
 	if nil != err {
 		return []int{}, err
 	}
@@ -121,8 +170,17 @@ func (client *SchemaRegistryClient) GetVersions(subject string) ([]int, error) {
 	return result, err
 }
 
-func (client *SchemaRegistryClient) getSchemaByVersionInternal(subject string, version string) (*goavro.Codec, error) {
-	resp, err := client.httpCall("GET", fmt.Sprintf(subjectByVersion, subject, version), nil)
+func (client *SchemaRegistryClient) getSchemaByVersionInternal(ctx context.Context, subject string, version string) (*goavro.Codec, error) {
+	schema, err := client.getSubjectVersion(ctx, subject, version)
+	if nil != err {
+		return nil, err
+	}
+	return goavro.NewCodec(schema.Schema)
+}
+
+// getSubjectVersion returns the version of the subject with its schema and id
+func (client *SchemaRegistryClient) getSubjectVersion(ctx context.Context, subject string, version string) (*schemaVersionResponse, error) {
+	resp, err := client.httpCall(ctx, "GET", fmt.Sprintf(subjectByVersion, subject, version), nil)
 	if nil != err {
 		return nil, err
 	}
@@ -131,29 +189,42 @@ func (client *SchemaRegistryClient) getSchemaByVersionInternal(subject string, v
 	if nil != err {
 		return nil, err
 	}
-
-	return goavro.NewCodec(schema.Schema)
+	return schema, nil
 }
 
 // GetSchemaByVersion returns a goavro.Codec for the version of the subject
 func (client *SchemaRegistryClient) GetSchemaByVersion(subject string, version int) (*goavro.Codec, error) {
-	return client.getSchemaByVersionInternal(subject, fmt.Sprintf("%d", version))
+	return client.GetSchemaByVersionContext(context.Background(), subject, version)
+}
+
+// GetSchemaByVersionContext returns a goavro.Codec for the version of the subject
+func (client *SchemaRegistryClient) GetSchemaByVersionContext(ctx context.Context, subject string, version int) (*goavro.Codec, error) {
+	return client.getSchemaByVersionInternal(ctx, subject, fmt.Sprintf("%d", version))
 }
 
 // GetLatestSchema returns a goavro.Codec for the latest version of the subject
 func (client *SchemaRegistryClient) GetLatestSchema(subject string) (*goavro.Codec, error) {
-	return client.getSchemaByVersionInternal(subject, latestVersion)
+	return client.GetLatestSchemaContext(context.Background(), subject)
+}
+
+// GetLatestSchemaContext returns a goavro.Codec for the latest version of the subject
+func (client *SchemaRegistryClient) GetLatestSchemaContext(ctx context.Context, subject string) (*goavro.Codec, error) {
+	return client.getSchemaByVersionInternal(ctx, subject, latestVersion)
 }
 
 // CreateSubject adds a schema to the subject
 func (client *SchemaRegistryClient) CreateSubject(subject string, codec *goavro.Codec) (int, error) {
+	return client.CreateSubjectContext(context.Background(), subject, codec)
+}
+
+// CreateSubjectContext adds a schema to the subject
+func (client *SchemaRegistryClient) CreateSubjectContext(ctx context.Context, subject string, codec *goavro.Codec) (int, error) {
 	schema := schemaResponse{codec.Schema()}
 	json, err := json.Marshal(schema)
 	if err != nil {
 		return 0, err
 	}
-	payload := bytes.NewBuffer(json)
-	resp, err := client.httpCall("POST", fmt.Sprintf(subjectVersions, subject), payload)
+	resp, err := client.httpCall(ctx, "POST", fmt.Sprintf(subjectVersions, subject), json)
 	if err != nil {
 		return 0, err
 	}
@@ -162,13 +233,17 @@ func (client *SchemaRegistryClient) CreateSubject(subject string, codec *goavro.
 
 // IsSchemaRegistered tests if the schema is registered, if so it returns the unique id of that schema
 func (client *SchemaRegistryClient) IsSchemaRegistered(subject string, codec *goavro.Codec) (int, error) {
+	return client.IsSchemaRegisteredContext(context.Background(), subject, codec)
+}
+
+// IsSchemaRegisteredContext tests if the schema is registered, if so it returns the unique id of that schema
+func (client *SchemaRegistryClient) IsSchemaRegisteredContext(ctx context.Context, subject string, codec *goavro.Codec) (int, error) {
 	schema := schemaResponse{codec.Schema()}
 	json, err := json.Marshal(schema)
 	if err != nil {
 		return 0, err
 	}
-	payload := bytes.NewBuffer(json)
-	resp, err := client.httpCall("POST", fmt.Sprintf(deleteSubject, subject), payload)
+	resp, err := client.httpCall(ctx, "POST", fmt.Sprintf(deleteSubject, subject), json)
 	if err != nil {
 		return 0, err
 	}
@@ -177,13 +252,23 @@ func (client *SchemaRegistryClient) IsSchemaRegistered(subject string, codec *go
 
 // DeleteSubject deletes a subject. It should only be used in development
 func (client *SchemaRegistryClient) DeleteSubject(subject string) error {
-	_, err := client.httpCall("DELETE", fmt.Sprintf(deleteSubject, subject), nil)
+	return client.DeleteSubjectContext(context.Background(), subject)
+}
+
+// DeleteSubjectContext deletes a subject. It should only be used in development
+func (client *SchemaRegistryClient) DeleteSubjectContext(ctx context.Context, subject string) error {
+	_, err := client.httpCall(ctx, "DELETE", fmt.Sprintf(deleteSubject, subject), nil)
 	return err
 }
 
 // DeleteVersion deletes a subject. It should only be used in development
 func (client *SchemaRegistryClient) DeleteVersion(subject string, version int) error {
-	_, err := client.httpCall("DELETE", fmt.Sprintf(subjectByVersion, subject, fmt.Sprintf("%d", version)), nil)
+	return client.DeleteVersionContext(context.Background(), subject, version)
+}
+
+// DeleteVersionContext deletes a subject. It should only be used in development
+func (client *SchemaRegistryClient) DeleteVersionContext(ctx context.Context, subject string, version int) error {
+	_, err := client.httpCall(ctx, "DELETE", fmt.Sprintf(subjectByVersion, subject, fmt.Sprintf("%d", version)), nil)
 	return err
 }
 
@@ -199,42 +284,95 @@ func parseID(str []byte) (int, error) {
 	return id.ID, err
 }
 
-func (client *SchemaRegistryClient) httpCall(method, uri string, payload io.Reader) ([]byte, error) {
-	nServers := int64(len(client.SchemaRegistryConnect))
-	n, err := rand.Int(rand.Reader, big.NewInt(nServers))
-	if err != nil {
-		return nil, err
+// httpCall sends the request to the healthiest server, retrying on the next ones as the RetryPolicy allows
+func (client *SchemaRegistryClient) httpCall(ctx context.Context, method, uri string, payload []byte) ([]byte, error) {
+	servers := client.endpoints.order()
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("no schema registry servers configured")
 	}
-	offset := n.Int64()
+	start := time.Now()
 	for i := 0; ; i++ {
-		url := fmt.Sprintf("%s%s", client.SchemaRegistryConnect[(int64(i)+offset)%nServers], uri)
-		req, err := http.NewRequest(method, url, payload)
+		server := servers[i%len(servers)]
+		req, err := client.newRequest(ctx, method, fmt.Sprintf("%s%s", server, uri), payload)
 		if err != nil {
 			return nil, err
 		}
-		req.Header.Set("Content-Type", contentType)
-		if client.SASL != nil {
-			req.SetBasicAuth(client.SASL.Username, client.SASL.Password)
+		resp, body, err := client.send(req)
+		switch {
+		case ctx.Err() != nil:
+			// a cancelled request says nothing about the health of the server
+		case err != nil:
+			client.endpoints.failure(server, err)
+		case resp.StatusCode >= 500:
+			client.endpoints.failure(server, newError(resp.StatusCode, body))
+		default:
+			client.endpoints.success(server)
+		}
+		if err == nil && okStatus(resp) {
+			return body, nil
+		}
+		if err == nil && !retriable(resp) {
+			return nil, newError(resp.StatusCode, body)
 		}
-		resp, err := client.httpClient.Do(req)
+
+		delay := client.retryPolicy.backoff(i + 1)
 		if resp != nil {
-			defer resp.Body.Close()
+			if after, ok := retryAfter(resp); ok {
+				delay = after
+			}
 		}
-		if i < client.retries && (err != nil || retriable(resp)) {
-			continue
+		maxElapsed := client.retryPolicy.MaxElapsedTime
+		if i >= client.retries || ctx.Err() != nil || (maxElapsed > 0 && time.Since(start)+delay > maxElapsed) {
+			if err != nil {
+				return nil, err
+			}
+			return nil, newError(resp.StatusCode, body)
 		}
-		if err != nil {
-			return nil, err
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
 		}
-		if !okStatus(resp) {
-			return nil, newError(resp)
+	}
+}
+
+// newRequest creates an authenticated request, every attempt has its own reader of payload
+func (client *SchemaRegistryClient) newRequest(ctx context.Context, method, url string, payload []byte) (*http.Request, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if client.Auth != nil {
+		if err := client.Auth.Authenticate(req); err != nil {
+			return nil, err
 		}
-		return ioutil.ReadAll(resp.Body)
+	} else if client.SASL != nil {
+		req.SetBasicAuth(client.SASL.Username, client.SASL.Password)
+	}
+	return req, nil
+}
+
+// send returns the response with its body read and closed
+func (client *SchemaRegistryClient) send(req *http.Request) (*http.Response, []byte, error) {
+	resp, err := client.httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
 	}
+	return resp, body, nil
 }
 
 func retriable(resp *http.Response) bool {
-	return resp.StatusCode >= 500 && resp.StatusCode < 600
+	return resp.StatusCode == http.StatusTooManyRequests || (resp.StatusCode >= 500 && resp.StatusCode < 600)
 }
 
 func okStatus(resp *http.Response) bool {
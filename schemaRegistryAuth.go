@@ -0,0 +1,95 @@
+package kafka
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SchemaRegistryAuth authenticates the requests of a SchemaRegistryClient
+type SchemaRegistryAuth interface {
+	Authenticate(req *http.Request) error
+}
+
+// BasicAuth sends credentials of their own, like the API keys of Confluent Cloud registries
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+func (a BasicAuth) Authenticate(req *http.Request) error {
+	req.SetBasicAuth(a.Username, a.Password)
+	return nil
+}
+
+// TokenSource returns a bearer token and the time it expires, the zero time when it does not expire
+type TokenSource func() (token string, expiry time.Time, err error)
+
+// tokenRefreshMargin is how long before its expiry a token is refreshed
+const tokenRefreshMargin = 30 * time.Second
+
+// BearerAuth sends a bearer token, fetched from its TokenSource and cached until shortly before it expires
+type BearerAuth struct {
+	source TokenSource
+	lock   sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// NewBearerAuth returns a BearerAuth refreshing its token with source
+func NewBearerAuth(source TokenSource) *BearerAuth {
+	return &BearerAuth{source: source}
+}
+
+// StaticBearerAuth returns a BearerAuth always sending token
+func StaticBearerAuth(token string) *BearerAuth {
+	return NewBearerAuth(func() (string, time.Time, error) {
+		return token, time.Time{}, nil
+	})
+}
+
+func (a *BearerAuth) Authenticate(req *http.Request) error {
+	token, err := a.Token()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Token returns the cached token, or a new one from the TokenSource once it is about to expire
+func (a *BearerAuth) Token() (string, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.token != "" && (a.expiry.IsZero() || time.Now().Add(tokenRefreshMargin).Before(a.expiry)) {
+		return a.token, nil
+	}
+	token, expiry, err := a.source()
+	if err != nil {
+		return "", err
+	}
+	a.token, a.expiry = token, expiry
+	return token, nil
+}
+
+// HeadersAuth adds headers to every request, like target-sr-cluster and Confluent-Identity-Pool-Id
+type HeadersAuth map[string]string
+
+func (a HeadersAuth) Authenticate(req *http.Request) error {
+	for key, value := range a {
+		req.Header.Set(key, value)
+	}
+	return nil
+}
+
+// MultiAuth applies each of its authentications, e.g. a bearer token and extra headers
+type MultiAuth []SchemaRegistryAuth
+
+func (a MultiAuth) Authenticate(req *http.Request) error {
+	for _, auth := range a {
+		if err := auth.Authenticate(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
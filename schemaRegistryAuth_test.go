@@ -0,0 +1,68 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSchemaRegistryClient_Auth(t *testing.T) {
+	var header http.Header
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		fmt.Fprint(w, `["test"]`)
+	}))
+	defer mockServer.Close()
+	client := NewSchemaRegistryClient([]string{mockServer.URL}, &SASLConfig{Username: "kafka", Password: "secret"})
+
+	client.GetSubjects()
+	if username, password, _ := (&http.Request{Header: header}).BasicAuth(); username != "kafka" || password != "secret" {
+		t.Errorf("Expected the SASL credentials without an auth, got %s:%s", username, password)
+	}
+
+	client.Auth = BasicAuth{"key", "api-secret"}
+	client.GetSubjects()
+	if username, password, _ := (&http.Request{Header: header}).BasicAuth(); username != "key" || password != "api-secret" {
+		t.Errorf("Expected the registry credentials, got %s:%s", username, password)
+	}
+
+	client.Auth = MultiAuth{StaticBearerAuth("token"), HeadersAuth{"target-sr-cluster": "lsrc-1"}}
+	client.GetSubjects()
+	if header.Get("Authorization") != "Bearer token" || header.Get("target-sr-cluster") != "lsrc-1" {
+		t.Errorf("Expected the bearer token and the extra header, got %v", header)
+	}
+
+	authErr := errors.New("no token")
+	client.Auth = NewBearerAuth(func() (string, time.Time, error) {
+		return "", time.Time{}, authErr
+	})
+	if _, err := client.GetSubjects(); err != authErr {
+		t.Errorf("Expected the token error, got %v", err)
+	}
+}
+
+func TestBearerAuth_Token(t *testing.T) {
+	var calls int
+	expiry := time.Now().Add(time.Hour)
+	auth := NewBearerAuth(func() (string, time.Time, error) {
+		calls++
+		return fmt.Sprintf("token-%d", calls), expiry, nil
+	})
+	for i := 0; i < 2; i++ {
+		if token, _ := auth.Token(); token != "token-1" {
+			t.Errorf("Expected the cached token, got %s", token)
+		}
+	}
+
+	expiry = time.Now().Add(tokenRefreshMargin / 2)
+	auth.expiry = expiry
+	if token, _ := auth.Token(); token != "token-2" {
+		t.Errorf("Expected a token about to expire to be refreshed, got %s", token)
+	}
+	if token, _ := auth.Token(); token != "token-3" {
+		t.Errorf("Expected the token to be refreshed again, got %s", token)
+	}
+}
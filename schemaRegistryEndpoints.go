@@ -0,0 +1,142 @@
+package kafka
+
+import (
+	"crypto/rand"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// CircuitBreaker ejects a schema registry server after FailureThreshold consecutive failures,
+// http errors and 5XX responses, for OpenTimeout. The server is then tried again, a success closes the
+// circuit and a failure ejects it again. Ejected servers are only used when no other server is left.
+type CircuitBreaker struct {
+	FailureThreshold int
+	OpenTimeout      time.Duration
+}
+
+// defaultCircuitBreaker is the CircuitBreaker of clients created without WithCircuitBreaker
+var defaultCircuitBreaker = CircuitBreaker{
+	FailureThreshold: 3,
+	OpenTimeout:      30 * time.Second,
+}
+
+// EndpointStatus is the health of a schema registry server
+type EndpointStatus struct {
+	URL string
+	// Healthy is false while the server is ejected by the circuit breaker
+	Healthy             bool
+	ConsecutiveFailures int
+	LastError           error
+	LastFailure         time.Time
+	// EjectedUntil is when an ejected server is tried again
+	EjectedUntil time.Time
+}
+
+type endpoint struct {
+	url          string
+	failures     int
+	lastError    error
+	lastFailure  time.Time
+	ejectedUntil time.Time
+}
+
+// endpoints tracks the health of the servers of a client
+type endpoints struct {
+	lock      sync.Mutex
+	endpoints []*endpoint
+	breaker   CircuitBreaker
+}
+
+func newEndpoints(urls []string, breaker CircuitBreaker) *endpoints {
+	e := &endpoints{breaker: breaker}
+	for _, url := range urls {
+		e.endpoints = append(e.endpoints, &endpoint{url: url})
+	}
+	return e
+}
+
+// order returns the urls in the order a request tries them: the healthy servers starting at a random one,
+// then the ejected servers whose timeout expired, then the ejected ones
+func (e *endpoints) order() []string {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	now := time.Now()
+	var healthy, expired, ejected []string
+	for _, ep := range e.endpoints {
+		switch {
+		case ep.ejectedUntil.IsZero():
+			healthy = append(healthy, ep.url)
+		case now.After(ep.ejectedUntil):
+			expired = append(expired, ep.url)
+		default:
+			ejected = append(ejected, ep.url)
+		}
+	}
+	if len(healthy) > 1 {
+		if n, err := rand.Int(rand.Reader, big.NewInt(int64(len(healthy)))); err == nil {
+			offset := int(n.Int64())
+			healthy = append(healthy[offset:], healthy[:offset]...)
+		}
+	}
+	return append(append(healthy, expired...), ejected...)
+}
+
+func (e *endpoints) get(url string) *endpoint {
+	for _, ep := range e.endpoints {
+		if ep.url == url {
+			return ep
+		}
+	}
+	return nil
+}
+
+// success closes the circuit of url
+func (e *endpoints) success(url string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if ep := e.get(url); ep != nil {
+		ep.failures = 0
+		ep.ejectedUntil = time.Time{}
+	}
+}
+
+// failure records a failure of url and ejects it once the threshold is reached
+func (e *endpoints) failure(url string, err error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	ep := e.get(url)
+	if ep == nil {
+		return
+	}
+	ep.failures++
+	ep.lastError = err
+	ep.lastFailure = time.Now()
+	if e.breaker.FailureThreshold > 0 && ep.failures >= e.breaker.FailureThreshold {
+		ep.ejectedUntil = ep.lastFailure.Add(e.breaker.OpenTimeout)
+	}
+}
+
+func (e *endpoints) status() []EndpointStatus {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	var statuses []EndpointStatus
+	for _, ep := range e.endpoints {
+		statuses = append(statuses, EndpointStatus{
+			URL:                 ep.url,
+			Healthy:             ep.ejectedUntil.IsZero(),
+			ConsecutiveFailures: ep.failures,
+			LastError:           ep.lastError,
+			LastFailure:         ep.lastFailure,
+			EjectedUntil:        ep.ejectedUntil,
+		})
+	}
+	return statuses
+}
+
+// WithCircuitBreaker ejects failing servers as configured by breaker, a FailureThreshold of 0 disables ejection
+func WithCircuitBreaker(breaker CircuitBreaker) SchemaRegistryOption {
+	return func(options *schemaRegistryOptions) {
+		options.circuitBreaker = breaker
+	}
+}
@@ -0,0 +1,73 @@
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEndpoints(t *testing.T) {
+	e := newEndpoints([]string{"http://a", "http://b"}, CircuitBreaker{FailureThreshold: 2, OpenTimeout: time.Minute})
+	failure := errors.New("connection refused")
+
+	e.failure("http://a", failure)
+	if status := e.status(); !status[0].Healthy || status[0].ConsecutiveFailures != 1 || status[0].LastError != failure {
+		t.Errorf("Expected a single failure to keep the server, got %+v", status[0])
+	}
+	e.failure("http://a", failure)
+	status := e.status()
+	if status[0].Healthy || status[0].EjectedUntil.Before(time.Now()) {
+		t.Errorf("Expected the server to be ejected, got %+v", status[0])
+	}
+	for i := 0; i < 10; i++ {
+		if order := e.order(); !reflect.DeepEqual(order, []string{"http://b", "http://a"}) {
+			t.Fatalf("Expected the ejected server last, got %v", order)
+		}
+	}
+
+	e.endpoints[0].ejectedUntil = time.Now().Add(-time.Second)
+	e.failure("http://b", failure)
+	e.failure("http://b", failure)
+	if order := e.order(); !reflect.DeepEqual(order, []string{"http://a", "http://b"}) {
+		t.Errorf("Expected the expired server before the ejected one, got %v", order)
+	}
+	e.success("http://a")
+	if status := e.status(); !status[0].Healthy || status[0].ConsecutiveFailures != 0 {
+		t.Errorf("Expected a success to close the circuit, got %+v", status[0])
+	}
+}
+
+func TestSchemaRegistryClient_EndpointStatus(t *testing.T) {
+	var count int
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		fmt.Fprint(w, `["test"]`)
+	}))
+	defer mockServer.Close()
+	deadServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error_code": 500, "message": "Error in the backend datastore"}`, 500)
+	}))
+	defer deadServer.Close()
+
+	client := NewCachedSchemaRegistryClientWithOptions([]string{deadServer.URL, mockServer.URL},
+		WithRetryPolicy(RetryPolicy{}), WithCircuitBreaker(CircuitBreaker{FailureThreshold: 1, OpenTimeout: time.Minute}))
+	for i := 0; i < 10; i++ {
+		if _, err := client.GetSubjects(); err != nil {
+			t.Fatalf("Found error %s", err)
+		}
+	}
+	if count != 10 {
+		t.Errorf("Expected every request to be answered by the healthy server, got %d", count)
+	}
+	status := client.EndpointStatus()
+	if status[0].URL != deadServer.URL || status[0].Healthy || status[0].ConsecutiveFailures != 1 {
+		t.Errorf("Expected the failing server to be ejected after one failure, got %+v", status[0])
+	}
+	if !status[1].Healthy {
+		t.Errorf("Expected the other server to be healthy, got %+v", status[1])
+	}
+}
@@ -0,0 +1,164 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// SchemaRegistryOption configures a SchemaRegistryClient created by NewSchemaRegistryClientWithOptions
+type SchemaRegistryOption func(options *schemaRegistryOptions)
+
+type schemaRegistryOptions struct {
+	httpClient          *http.Client
+	transport           http.RoundTripper
+	timeout             time.Duration
+	proxy               func(*http.Request) (*url.URL, error)
+	tlsConfig           *tls.Config
+	retries             int
+	sasl                *SASLConfig
+	auth                SchemaRegistryAuth
+	subjectNameStrategy SubjectNameStrategy
+	retryPolicy         RetryPolicy
+	circuitBreaker      CircuitBreaker
+	cacheConfig         CacheConfig
+	schemaStore         SchemaStore
+	schemaStoreErrors   func(err error)
+}
+
+// WithHTTPClient sends the requests with httpClient, it is copied so the other options do not modify it
+func WithHTTPClient(httpClient *http.Client) SchemaRegistryOption {
+	return func(options *schemaRegistryOptions) {
+		options.httpClient = httpClient
+	}
+}
+
+// WithTransport sends the requests with transport, e.g. an instrumented round tripper.
+// The proxy and TLS options only apply to the default transport.
+func WithTransport(transport http.RoundTripper) SchemaRegistryOption {
+	return func(options *schemaRegistryOptions) {
+		options.transport = transport
+	}
+}
+
+// WithTimeout limits the time of every request, 2 seconds by default or the timeout of WithHTTPClient
+func WithTimeout(timeout time.Duration) SchemaRegistryOption {
+	return func(options *schemaRegistryOptions) {
+		options.timeout = timeout
+	}
+}
+
+// WithProxy sends the requests through the proxy returned by proxy, like http.ProxyFromEnvironment
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) SchemaRegistryOption {
+	return func(options *schemaRegistryOptions) {
+		options.proxy = proxy
+	}
+}
+
+// WithTLSConfig connects with tlsConfig, see NewSchemaRegistryClientWithTLS
+func WithTLSConfig(tlsConfig *tls.Config) SchemaRegistryOption {
+	return func(options *schemaRegistryOptions) {
+		options.tlsConfig = tlsConfig
+	}
+}
+
+// WithRetries retries failed requests (5XX responses and http errors) retries times, len(connect) times by default
+func WithRetries(retries int) SchemaRegistryOption {
+	return func(options *schemaRegistryOptions) {
+		options.retries = retries
+	}
+}
+
+// WithSASL sends the SASL username and password as basic auth, unless WithAuth is given
+func WithSASL(saslConfig *SASLConfig) SchemaRegistryOption {
+	return func(options *schemaRegistryOptions) {
+		options.sasl = saslConfig
+	}
+}
+
+// WithAuth authenticates the requests with auth
+func WithAuth(auth SchemaRegistryAuth) SchemaRegistryOption {
+	return func(options *schemaRegistryOptions) {
+		options.auth = auth
+	}
+}
+
+// WithSubjectNameStrategy maps topics to subjects with strategy
+func WithSubjectNameStrategy(strategy SubjectNameStrategy) SchemaRegistryOption {
+	return func(options *schemaRegistryOptions) {
+		options.subjectNameStrategy = strategy
+	}
+}
+
+// NewSchemaRegistryClientWithOptions creates a client to talk with the schema registry at the connect string
+func NewSchemaRegistryClientWithOptions(connect []string, opts ...SchemaRegistryOption) *SchemaRegistryClient {
+	return newSchemaRegistryOptions(connect, opts).client(connect)
+}
+
+func newSchemaRegistryOptions(connect []string, opts []SchemaRegistryOption) *schemaRegistryOptions {
+	options := &schemaRegistryOptions{
+		retries:        len(connect),
+		retryPolicy:    defaultRetryPolicy,
+		circuitBreaker: defaultCircuitBreaker,
+		cacheConfig:    defaultCacheConfig,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+func (options *schemaRegistryOptions) client(connect []string) *SchemaRegistryClient {
+	httpClient := http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:           options.proxy,
+			TLSClientConfig: registryTLSConfig(options.tlsConfig, options.sasl),
+		},
+	}
+	if options.httpClient != nil {
+		httpClient = *options.httpClient
+	}
+	if options.transport != nil {
+		httpClient.Transport = options.transport
+	}
+	if options.timeout > 0 {
+		httpClient.Timeout = options.timeout
+	}
+	return &SchemaRegistryClient{connect, &httpClient, options.retries, options.sasl, options.subjectNameStrategy, options.auth, options.retryPolicy, newEndpoints(connect, options.circuitBreaker)}
+}
+
+// schemaRegistrySettings are the schema registry settings of AvroProducerConfig and AvroConsumerConfig,
+// both create their client from them so they configure it the same way
+type schemaRegistrySettings struct {
+	servers  []string
+	sasl     *SASLConfig
+	tls      *tls.Config
+	auth     SchemaRegistryAuth
+	options  []SchemaRegistryOption
+	strategy SubjectNameStrategy
+	client   SchemaRegistryClientInterface
+}
+
+// newClient returns client, mapping topics to subjects with strategy when set,
+// or a cached client created from the other settings
+func (settings schemaRegistrySettings) newClient() SchemaRegistryClientInterface {
+	if settings.client == nil {
+		return NewCachedSchemaRegistryClientWithOptions(settings.servers, settings.clientOptions()...)
+	}
+	if settings.strategy != nil {
+		return &strategyClient{settings.client, settings.strategy}
+	}
+	return settings.client
+}
+
+// clientOptions returns the options of the schema registry client, the caller's options come last
+func (settings schemaRegistrySettings) clientOptions() []SchemaRegistryOption {
+	return append([]SchemaRegistryOption{
+		WithSASL(settings.sasl),
+		WithTLSConfig(settings.tls),
+		WithAuth(settings.auth),
+		WithSubjectNameStrategy(settings.strategy),
+	}, settings.options...)
+}
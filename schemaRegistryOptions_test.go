@@ -0,0 +1,81 @@
+package kafka
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type testRoundTripper struct {
+	requests int
+}
+
+func (rt *testRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.requests++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestNewSchemaRegistryClientWithOptions(t *testing.T) {
+	client := NewSchemaRegistryClientWithOptions([]string{"http://a", "http://b"})
+	if client.retries != 2 || client.httpClient.Timeout != timeout {
+		t.Errorf("Expected the default retries and timeout, got %d and %v", client.retries, client.httpClient.Timeout)
+	}
+	if transport := client.httpClient.Transport.(*http.Transport); transport.Proxy != nil || transport.TLSClientConfig != nil {
+		t.Errorf("Expected a verifying transport without a proxy")
+	}
+
+	proxyURL, _ := url.Parse("http://proxy:3128")
+	client = NewSchemaRegistryClientWithOptions([]string{"http://a"}, WithRetries(0), WithTimeout(time.Second), WithProxy(http.ProxyURL(proxyURL)))
+	if client.retries != 0 || client.httpClient.Timeout != time.Second {
+		t.Errorf("Expected the given retries and timeout, got %d and %v", client.retries, client.httpClient.Timeout)
+	}
+	req, _ := http.NewRequest("GET", "http://a/subjects", nil)
+	if proxy, _ := client.httpClient.Transport.(*http.Transport).Proxy(req); proxy.String() != proxyURL.String() {
+		t.Errorf("Expected the proxy %s, got %s", proxyURL, proxy)
+	}
+
+	httpClient := &http.Client{Timeout: 5 * time.Second}
+	client = NewSchemaRegistryClientWithOptions([]string{"http://a"}, WithHTTPClient(httpClient))
+	if client.httpClient.Timeout != 5*time.Second {
+		t.Errorf("Expected the timeout of the http client, got %v", client.httpClient.Timeout)
+	}
+	client = NewSchemaRegistryClientWithOptions([]string{"http://a"}, WithHTTPClient(httpClient), WithTimeout(time.Second))
+	if client.httpClient.Timeout != time.Second || httpClient.Timeout != 5*time.Second {
+		t.Errorf("Expected the timeout to be set on a copy of the http client")
+	}
+}
+
+func TestNewSchemaRegistryClientWithOptions_Transport(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `["test"]`)
+	}))
+	defer mockServer.Close()
+	transport := &testRoundTripper{}
+	client := NewCachedSchemaRegistryClientWithOptions([]string{mockServer.URL}, WithTransport(transport), WithSubjectNameStrategy(RecordNameStrategy))
+	if _, err := client.GetSubjects(); err != nil {
+		t.Errorf("Found error %s", err)
+	}
+	if transport.requests != 1 {
+		t.Errorf("Expected the request to go through the transport, got %d requests", transport.requests)
+	}
+	if client.SchemaRegistryClient.SubjectNameStrategy == nil {
+		t.Errorf("Expected the subject name strategy to be set")
+	}
+}
+
+func TestSchemaRegistrySettings_newClient(t *testing.T) {
+	if _, ok := (schemaRegistrySettings{servers: []string{"http://localhost:8081"}}).newClient().(*CachedSchemaRegistryClient); !ok {
+		t.Errorf("Expected a cached client when no client is given")
+	}
+	mock := NewMockSchemaRegistryClient()
+	if client := (schemaRegistrySettings{client: mock}).newClient(); client != mock {
+		t.Errorf("Expected the given client, got %T", client)
+	}
+	client := schemaRegistrySettings{client: mock, strategy: RecordNameStrategy}.newClient()
+	if _, ok := client.(*strategyClient); !ok {
+		t.Errorf("Expected the given client to map subjects with the strategy, got %T", client)
+	}
+}
@@ -0,0 +1,70 @@
+package kafka
+
+import (
+	mathrand "math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy spaces the retries of failed registry requests, the number of retries is set by WithRetries
+type RetryPolicy struct {
+	// InitialBackoff is the delay before the first retry, it is doubled for every following retry
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries, no cap when 0
+	MaxBackoff time.Duration
+	// Jitter is the fraction of the delay that is randomized, between 0 and 1,
+	// so clients failing together do not retry together
+	Jitter float64
+	// MaxElapsedTime stops retrying once the next attempt would start this long after the first, no limit when 0
+	MaxElapsedTime time.Duration
+}
+
+// defaultRetryPolicy is the RetryPolicy of clients created without WithRetryPolicy
+var defaultRetryPolicy = RetryPolicy{
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+	Jitter:         0.5,
+	MaxElapsedTime: 30 * time.Second,
+}
+
+// backoff returns the delay before the given retry, starting at 1
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < retry && (p.MaxBackoff == 0 || delay < p.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		delay -= time.Duration(p.Jitter * mathrand.Float64() * float64(delay))
+	}
+	return delay
+}
+
+// retryAfter returns the delay requested by the Retry-After header of a 429 or 503 response,
+// given in seconds or as an http date
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// WithRetryPolicy spaces the retries of failed requests with policy
+func WithRetryPolicy(policy RetryPolicy) SchemaRegistryOption {
+	return func(options *schemaRegistryOptions) {
+		options.retryPolicy = policy
+	}
+}
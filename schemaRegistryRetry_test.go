@@ -0,0 +1,115 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i, delay := range expected {
+		if backoff := policy.backoff(i + 1); backoff != delay {
+			t.Errorf("Expected backoff %v for retry %d, got %v", delay, i+1, backoff)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if backoff := policy.backoff(2); backoff < 100*time.Millisecond || backoff > 200*time.Millisecond {
+			t.Fatalf("Expected a jittered backoff between 100ms and 200ms, got %v", backoff)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		header string
+		delay  time.Duration
+		ok     bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, true},
+		{"soon", 0, false},
+	}
+	for _, test := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		resp.Header.Set("Retry-After", test.header)
+		if delay, ok := retryAfter(resp); delay != test.delay || ok != test.ok {
+			t.Errorf("Expected %v, %v for %q, got %v, %v", test.delay, test.ok, test.header, delay, ok)
+		}
+	}
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("Retry-After", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	if delay, ok := retryAfter(resp); !ok || delay < 58*time.Second || delay > time.Minute {
+		t.Errorf("Expected about a minute for an http date, got %v", delay)
+	}
+}
+
+func TestSchemaRegistryClient_RetryReplaysBody(t *testing.T) {
+	schemaRegistryTestObject := createSchemaRegistryTestObject(t, "test", 1)
+	var bodies []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		switch len(bodies) {
+		case 1:
+			http.Error(w, `{"error_code": 500, "message": "Error in the backend datastore"}`, 500)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, `{"error_code": 429, "message": "Too many requests"}`, 429)
+		default:
+			fmt.Fprint(w, `{"id": 7}`)
+		}
+	}))
+	defer mockServer.Close()
+	client := NewSchemaRegistryClientWithOptions([]string{mockServer.URL}, WithRetries(2), WithRetryPolicy(RetryPolicy{InitialBackoff: time.Millisecond}))
+
+	id, err := client.CreateSubject("test-value", schemaRegistryTestObject.Codec)
+	if err != nil || id != 7 {
+		t.Errorf("Expected id 7, got %d, %v", id, err)
+	}
+	if len(bodies) != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", len(bodies))
+	}
+	for i, body := range bodies {
+		if body == "" || body != bodies[0] {
+			t.Errorf("Expected attempt %d to send the schema, got %q", i+1, body)
+		}
+	}
+}
+
+func TestSchemaRegistryClient_RetryLimits(t *testing.T) {
+	var count int
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		http.Error(w, `{"error_code": 500, "message": "Error in the backend datastore"}`, 500)
+	}))
+	defer mockServer.Close()
+
+	client := NewSchemaRegistryClientWithOptions([]string{mockServer.URL}, WithRetries(10), WithRetryPolicy(RetryPolicy{InitialBackoff: 50 * time.Millisecond, MaxElapsedTime: 120 * time.Millisecond}))
+	_, err := client.GetSubjects()
+	if err == nil || err.Error() != (&Error{500, "Error in the backend datastore"}).Error() {
+		t.Errorf("Expected the registry error, got %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected the max elapsed time to stop after 2 attempts, got %d", count)
+	}
+
+	count = 0
+	client = NewSchemaRegistryClientWithOptions([]string{mockServer.URL}, WithRetries(10), WithRetryPolicy(RetryPolicy{InitialBackoff: time.Minute}))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.GetSubjectsContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected the backoff to end with the context, got %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected a single attempt, got %d", count)
+	}
+}
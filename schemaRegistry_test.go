@@ -1,6 +1,8 @@
 package kafka
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -18,6 +20,7 @@ type TestObject struct {
 	Subject    string
 	Id         int
 	Count      int
+	Registered []string
 }
 
 func createSchemaRegistryTestObject(t *testing.T, subject string, id int) *TestObject {
@@ -35,10 +38,19 @@ func createSchemaRegistryTestObject(t *testing.T, subject string, id int) *TestO
 		testObject.Count++
 		if r.Method == "POST" {
 			switch r.URL.String() {
-			case fmt.Sprintf(subjectVersions, subject), fmt.Sprintf(deleteSubject, subject):
+			case fmt.Sprintf(deleteSubject, subject):
 				response := idResponse{id}
 				str, _ := json.Marshal(response)
 				fmt.Fprintf(w, string(str))
+			default:
+				// accept registrations under any subject and record it, so name strategies can be asserted
+				var registered string
+				if _, err := fmt.Sscanf(r.URL.String(), "/subjects/%s", &registered); err == nil && strings.HasSuffix(registered, "/versions") {
+					testObject.Registered = append(testObject.Registered, strings.TrimSuffix(registered, "/versions"))
+					response := idResponse{id}
+					str, _ := json.Marshal(response)
+					fmt.Fprintf(w, string(str))
+				}
 			}
 		} else if r.Method == "GET" {
 			switch r.URL.String() {
@@ -57,6 +69,10 @@ func createSchemaRegistryTestObject(t *testing.T, subject string, id int) *TestO
 				response := schemaVersionResponse{subject, 1, codec.Schema(), id}
 				str, _ := json.Marshal(response)
 				fmt.Fprintf(w, string(str))
+			default:
+				if strings.HasPrefix(r.URL.String(), "/schemas/ids/") {
+					http.Error(w, `{"error_code": 40403, "message": "Schema not found"}`, 404)
+				}
 			}
 		} else if r.Method == "DELETE" {
 			switch r.URL.String() {
@@ -133,3 +149,63 @@ func TestSchemaRegistryClient_Error(t *testing.T) {
 		t.Errorf("Expected error to be %s, got %s", expectedErr.Error(), err.Error())
 	}
 }
+
+func TestSchemaRegistryClient_TLS(t *testing.T) {
+	mockServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			http.Error(w, `{"error_code": 401, "message": "Client certificate required"}`, 401)
+			return
+		}
+		fmt.Fprint(w, `["test"]`)
+	}))
+	mockServer.TLS.ClientAuth = tls.RequestClientCert
+	defer mockServer.Close()
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(mockServer.Certificate())
+	clientCert := mockServer.TLS.Certificates[0]
+
+	if _, err := NewSchemaRegistryClient([]string{mockServer.URL}, nil).GetSubjects(); err == nil {
+		t.Errorf("Expected an unknown certificate authority to be rejected by default")
+	}
+	tlsConfig := &tls.Config{RootCAs: rootCAs, Certificates: []tls.Certificate{clientCert}}
+	subjects, err := NewSchemaRegistryClientWithTLS([]string{mockServer.URL}, tlsConfig, nil).GetSubjects()
+	if err != nil || !reflect.DeepEqual(subjects, []string{"test"}) {
+		t.Errorf("Expected subjects over mTLS, got %v, %v", subjects, err)
+	}
+	saslConfig := &SASLConfig{Username: "test", TLSConfig: tlsConfig}
+	if _, err := NewSchemaRegistryClient([]string{mockServer.URL}, saslConfig).GetSubjects(); err != nil {
+		t.Errorf("Expected the TLS config of SASL to be used, got %v", err)
+	}
+	insecure := &tls.Config{InsecureSkipVerify: true}
+	if _, err := NewSchemaRegistryClientWithTLS([]string{mockServer.URL}, insecure, nil).GetSubjects(); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected verification to be skipped on request and no client certificate to be sent, got %v", err)
+	}
+}
+
+func TestSchemaRegistryClient_SubjectPaths(t *testing.T) {
+	var paths []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		http.Error(w, `{"error_code": 40401, "message": "Subject not found"}`, 404)
+	}))
+	defer mockServer.Close()
+	codec, err := goavro.NewCodec(`"string"`)
+	if err != nil {
+		t.Fatalf("Could not create codec %v", err)
+	}
+	client := NewSchemaRegistryClient([]string{mockServer.URL}, nil)
+	client.GetLatestSchema("orders")
+	client.GetVersions("orders")
+	client.CreateSubject("orders", codec)
+	client.DeleteSubject("orders")
+	// subjects are used as given, no -value suffix is appended
+	expected := []string{
+		"GET /subjects/orders/versions/latest",
+		"GET /subjects/orders/versions",
+		"POST /subjects/orders/versions",
+		"DELETE /subjects/orders",
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected requests %v, got %v", expected, paths)
+	}
+}
@@ -0,0 +1,161 @@
+package kafka
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/linkedin/goavro"
+)
+
+// StoredSchema is a schema persisted by a SchemaStore, Subject is empty for schemas looked up by id
+type StoredSchema struct {
+	Id      int    `json:"id"`
+	Subject string `json:"subject,omitempty"`
+	Schema  string `json:"schema"`
+}
+
+// SchemaStore persists the schemas of a CachedSchemaRegistryClient, so a restarted client knows them
+// while the schema registry is unreachable
+type SchemaStore interface {
+	// Load returns the stored schemas
+	Load() ([]StoredSchema, error)
+	// Save stores a schema looked up by id or registered to a subject
+	Save(schema StoredSchema) error
+}
+
+// WithSchemaStore persists the schemas of a CachedSchemaRegistryClient to store. The client is warmed with the
+// stored schemas when created and falls back to them when the registry fails, it has no effect on a SchemaRegistryClient
+func WithSchemaStore(store SchemaStore) SchemaRegistryOption {
+	return func(options *schemaRegistryOptions) {
+		options.schemaStore = store
+	}
+}
+
+// WithSchemaStoreErrors reports to onError the errors of the store given to WithSchemaStore, the failures to load
+// the stored schemas when the client is created, stored schemas that are not valid and failures to save schemas.
+// onError may be called concurrently.
+func WithSchemaStoreErrors(onError func(err error)) SchemaRegistryOption {
+	return func(options *schemaRegistryOptions) {
+		options.schemaStoreErrors = onError
+	}
+}
+
+// FileSchemaStore is a SchemaStore keeping the schemas in a json file
+type FileSchemaStore struct {
+	path    string
+	lock    sync.Mutex
+	schemas []StoredSchema
+	loaded  bool
+}
+
+// NewFileSchemaStore creates a store in the file at path, the file is created with the first schema saved
+func NewFileSchemaStore(path string) *FileSchemaStore {
+	return &FileSchemaStore{path: path}
+}
+
+// Load reads the stored schemas from the file, it returns no schemas when the file does not exist
+func (store *FileSchemaStore) Load() ([]StoredSchema, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+	return append([]StoredSchema{}, store.schemas...), nil
+}
+
+// Save adds schema to the file unless it is already stored
+func (store *FileSchemaStore) Save(schema StoredSchema) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	if !store.loaded {
+		if err := store.load(); err != nil {
+			return err
+		}
+	}
+	for _, stored := range store.schemas {
+		if stored == schema {
+			return nil
+		}
+	}
+	store.schemas = append(store.schemas, schema)
+	return store.write()
+}
+
+func (store *FileSchemaStore) load() error {
+	data, err := ioutil.ReadFile(store.path)
+	if os.IsNotExist(err) {
+		store.schemas, store.loaded = nil, true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var schemas []StoredSchema
+	if err := json.Unmarshal(data, &schemas); err != nil {
+		return err
+	}
+	store.schemas, store.loaded = schemas, true
+	return nil
+}
+
+// write replaces the file with a temporary file, so a crash never leaves a partially written file
+func (store *FileSchemaStore) write() error {
+	data, err := json.Marshal(store.schemas)
+	if err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(filepath.Dir(store.path), filepath.Base(store.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), store.path)
+}
+
+// schemaIndex holds the stored schemas by id and by subject and compacted schema
+type schemaIndex struct {
+	lock    sync.RWMutex
+	schemas map[int]*goavro.Codec
+	ids     map[subjectSchemaKey]int
+}
+
+func newSchemaIndex() *schemaIndex {
+	return &schemaIndex{schemas: map[int]*goavro.Codec{}, ids: map[subjectSchemaKey]int{}}
+}
+
+// add indexes the codec with the given id, registered to subject unless it is empty
+func (index *schemaIndex) add(id int, subject string, codec *goavro.Codec) {
+	index.lock.Lock()
+	defer index.lock.Unlock()
+	index.schemas[id] = codec
+	if subject != "" {
+		index.ids[subjectSchemaKey{subject, compactSchema(codec)}] = id
+	}
+}
+
+func (index *schemaIndex) schema(id int) *goavro.Codec {
+	index.lock.RLock()
+	defer index.lock.RUnlock()
+	return index.schemas[id]
+}
+
+func (index *schemaIndex) id(key subjectSchemaKey) (int, bool) {
+	index.lock.RLock()
+	defer index.lock.RUnlock()
+	id, ok := index.ids[key]
+	return id, ok
+}
@@ -0,0 +1,120 @@
+package kafka
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileSchemaStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schemas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "schemas.json")
+
+	store := NewFileSchemaStore(path)
+	schemas, err := store.Load()
+	if err != nil || len(schemas) != 0 {
+		t.Errorf("Expected no schemas without a file, got %v, %v", schemas, err)
+	}
+	first := StoredSchema{Id: 1, Schema: `"string"`}
+	second := StoredSchema{Id: 1, Subject: "test-value", Schema: `"string"`}
+	for _, schema := range []StoredSchema{first, second, first} {
+		if err := store.Save(schema); err != nil {
+			t.Errorf("Error saving schema: %v", err)
+		}
+	}
+
+	schemas, err = NewFileSchemaStore(path).Load()
+	if err != nil {
+		t.Errorf("Error loading schemas: %v", err)
+	}
+	if len(schemas) != 2 || schemas[0] != first || schemas[1] != second {
+		t.Errorf("Expected the saved schemas once, got %v", schemas)
+	}
+}
+
+func TestCachedSchemaRegistryClient_SchemaStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schemas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := NewFileSchemaStore(filepath.Join(dir, "schemas.json"))
+	testObject := createSchemaRegistryTestObject(t, "test", 1)
+	client := NewCachedSchemaRegistryClientWithOptions([]string{testObject.MockServer.URL}, WithSchemaStore(store))
+	client.GetSchema(1)
+	client.CreateSubject("test-value", testObject.Codec)
+	testObject.MockServer.Close()
+
+	counted := &countingSchemaStore{SchemaStore: store}
+	restarted := NewCachedSchemaRegistryClientWithOptions([]string{testObject.MockServer.URL}, WithSchemaStore(counted), WithRetries(0))
+	codec, err := restarted.GetSchema(1)
+	if err != nil || codec.Schema() != testObject.Codec.Schema() {
+		t.Errorf("Expected the stored schema, got %v, %v", codec, err)
+	}
+	id, err := restarted.CreateSubject("test-value", testObject.Codec)
+	if err != nil || id != 1 {
+		t.Errorf("Expected the stored id, got %d, %v", id, err)
+	}
+
+	restarted.schemaCache.removeIf(func(interface{}) bool { return true })
+	restarted.schemaIdCache.removeIf(func(interface{}) bool { return true })
+	if _, err := restarted.GetSchema(1); err != nil {
+		t.Errorf("Expected the stored schema when the registry fails, got %v", err)
+	}
+	if _, err := restarted.CreateSubject("test-value", testObject.Codec); err != nil {
+		t.Errorf("Expected the stored id when the registry fails, got %v", err)
+	}
+	if _, err := restarted.CreateSubject("other-value", testObject.Codec); err == nil {
+		t.Errorf("Expected an error for a schema not stored for the subject")
+	}
+	if counted.loads != 1 {
+		t.Errorf("Expected the store to be loaded once, got %d loads", counted.loads)
+	}
+}
+
+func TestCachedSchemaRegistryClient_SchemaStoreErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schemas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "schemas.json")
+	if err := ioutil.WriteFile(path, []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var errs []error
+	onError := WithSchemaStoreErrors(func(err error) { errs = append(errs, err) })
+	NewCachedSchemaRegistryClientWithOptions([]string{"http://localhost"}, WithSchemaStore(NewFileSchemaStore(path)), onError)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "could not load the stored schemas") {
+		t.Errorf("Expected the load error to be reported, got %v", errs)
+	}
+
+	errs = nil
+	testObject := createSchemaRegistryTestObject(t, "test", 1)
+	defer testObject.MockServer.Close()
+	missing := NewFileSchemaStore(filepath.Join(dir, "missing", "schemas.json"))
+	client := NewCachedSchemaRegistryClientWithOptions([]string{testObject.MockServer.URL}, WithSchemaStore(missing), onError)
+	if _, err := client.GetSchema(1); err != nil {
+		t.Errorf("Expected a failing store not to fail the lookup, got %v", err)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "could not save schema 1") {
+		t.Errorf("Expected the save error to be reported, got %v", errs)
+	}
+}
+
+// countingSchemaStore counts the loads of a SchemaStore
+type countingSchemaStore struct {
+	SchemaStore
+	loads int
+}
+
+func (store *countingSchemaStore) Load() ([]StoredSchema, error) {
+	store.loads++
+	return store.SchemaStore.Load()
+}
@@ -0,0 +1,107 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// flightGroup collapses concurrent calls with the same key into one call whose result is shared
+type flightGroup struct {
+	// coalesced is first to be 64 bit aligned for atomic access
+	coalesced int64
+	lock      sync.Mutex
+	calls     map[interface{}]*flightCall
+}
+
+type flightCall struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+	// waiters is the number of callers waiting for the call, guarded by the lock of the group
+	waiters int
+	cancel  context.CancelFunc
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{calls: map[interface{}]*flightCall{}}
+}
+
+// do calls fn unless a call for key is in flight and waits for the result of the call. The call runs with
+// the values of ctx but without its deadline and cancellation, so one caller giving up does not fail the others,
+// every caller stops waiting when its own ctx is done. The call is cancelled once all its callers stopped waiting.
+func (g *flightGroup) do(ctx context.Context, key interface{}, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	g.lock.Lock()
+	call, ok := g.calls[key]
+	if ok {
+		atomic.AddInt64(&g.coalesced, 1)
+	} else {
+		callCtx, cancel := context.WithCancel(detachedContext{ctx})
+		call = &flightCall{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = call
+		go g.call(callCtx, key, call, fn)
+	}
+	call.waiters++
+	g.lock.Unlock()
+
+	select {
+	case <-call.done:
+		return call.value, call.err
+	case <-ctx.Done():
+		g.leave(key, call)
+		return nil, ctx.Err()
+	}
+}
+
+// leave cancels the call when the last caller stops waiting, later callers start a new call
+func (g *flightGroup) leave(key interface{}, call *flightCall) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	call.waiters--
+	if call.waiters > 0 {
+		return
+	}
+	call.cancel()
+	if g.calls[key] == call {
+		delete(g.calls, key)
+	}
+}
+
+func (g *flightGroup) call(ctx context.Context, key interface{}, call *flightCall, fn func(ctx context.Context) (interface{}, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			call.value, call.err = nil, fmt.Errorf("schema registry call panicked: %v", r)
+		}
+		g.lock.Lock()
+		if g.calls[key] == call {
+			delete(g.calls, key)
+		}
+		g.lock.Unlock()
+		call.cancel()
+		close(call.done)
+	}()
+	call.value, call.err = fn(ctx)
+}
+
+// coalescedCalls returns the number of calls that shared the result of a call in flight
+func (g *flightGroup) coalescedCalls() int64 {
+	return atomic.LoadInt64(&g.coalesced)
+}
+
+// detachedContext keeps the values of a context without its deadline and cancellation
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
@@ -0,0 +1,154 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFlightGroup(t *testing.T) {
+	group := newFlightGroup()
+	release := make(chan struct{})
+	calls := 0
+	results := make(chan interface{}, 5)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, _ := group.do(context.Background(), "key", func(context.Context) (interface{}, error) {
+				calls++
+				<-release
+				return "value", nil
+			})
+			results <- value
+		}()
+	}
+	waitFor(t, func() bool { return group.coalescedCalls() == 4 })
+	close(release)
+	wg.Wait()
+	close(results)
+
+	if calls != 1 {
+		t.Errorf("Expected 1 call, got %d", calls)
+	}
+	for value := range results {
+		if value != "value" {
+			t.Errorf("Expected the shared value, got %v", value)
+		}
+	}
+	if _, err := group.do(context.Background(), "key", func(context.Context) (interface{}, error) { return nil, errors.New("failed") }); err == nil {
+		t.Errorf("Expected a new call once the previous one finished")
+	}
+}
+
+func TestFlightGroup_CallerContext(t *testing.T) {
+	group := newFlightGroup()
+	release := make(chan struct{})
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error)
+	go func() {
+		_, err := group.do(first, "key", func(ctx context.Context) (interface{}, error) {
+			<-release
+			return "value", ctx.Err()
+		})
+		firstErr <- err
+	}()
+	waitFor(t, func() bool {
+		group.lock.Lock()
+		defer group.lock.Unlock()
+		return len(group.calls) == 1
+	})
+
+	second := make(chan interface{})
+	go func() {
+		value, err := group.do(context.Background(), "key", nil)
+		if err != nil {
+			t.Errorf("Expected the waiting caller to get the result, got %v", err)
+		}
+		second <- value
+	}()
+	waitFor(t, func() bool { return group.coalescedCalls() == 1 })
+
+	cancel()
+	if err := <-firstErr; err != context.Canceled {
+		t.Errorf("Expected the cancelled caller to give up, got %v", err)
+	}
+	close(release)
+	if value := <-second; value != "value" {
+		t.Errorf("Expected the shared value, got %v", value)
+	}
+
+	if _, err := group.do(first, "other", nil); err != context.Canceled {
+		t.Errorf("Expected no call for a cancelled caller, got %v", err)
+	}
+}
+
+func TestFlightGroup_LastCallerLeaves(t *testing.T) {
+	group := newFlightGroup()
+	first, cancelFirst := context.WithCancel(context.Background())
+	second, cancelSecond := context.WithCancel(context.Background())
+	cancelled := make(chan error, 1)
+	fn := func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		return nil, ctx.Err()
+	}
+	errs := make(chan error, 2)
+	go func() {
+		_, err := group.do(first, "key", fn)
+		errs <- err
+	}()
+	waitFor(t, func() bool {
+		group.lock.Lock()
+		defer group.lock.Unlock()
+		return len(group.calls) == 1
+	})
+	go func() {
+		_, err := group.do(second, "key", fn)
+		errs <- err
+	}()
+	waitFor(t, func() bool { return group.coalescedCalls() == 1 })
+
+	cancelFirst()
+	<-errs
+	select {
+	case <-cancelled:
+		t.Fatalf("Expected the call to go on while a caller is waiting")
+	case <-time.After(10 * time.Millisecond):
+	}
+	cancelSecond()
+	<-errs
+	select {
+	case err := <-cancelled:
+		if err != context.Canceled {
+			t.Errorf("Expected the call to be cancelled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected the call to be cancelled once the last caller left")
+	}
+	if value, err := group.do(context.Background(), "key", func(context.Context) (interface{}, error) { return "value", nil }); value != "value" || err != nil {
+		t.Errorf("Expected a new call after the cancelled one, got %v, %v", value, err)
+	}
+}
+
+func TestFlightGroup_Panic(t *testing.T) {
+	group := newFlightGroup()
+	_, err := group.do(context.Background(), "key", func(context.Context) (interface{}, error) { panic("registry exploded") })
+	if err == nil || !strings.Contains(err.Error(), "registry exploded") {
+		t.Errorf("Expected the panic value in the error, got %v", err)
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Condition not met")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,81 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/linkedin/goavro"
+)
+
+// SubjectNameStrategy returns the subject under which the key or value schema of a topic is registered
+type SubjectNameStrategy func(topic string, isKey bool, codec *goavro.Codec) (string, error)
+
+// TopicNameStrategy registers schemas under <topic>-key and <topic>-value, this is the default strategy
+func TopicNameStrategy(topic string, isKey bool, codec *goavro.Codec) (string, error) {
+	if isKey {
+		return topic + "-key", nil
+	}
+	return topic + "-value", nil
+}
+
+// RecordNameStrategy registers schemas under the fully-qualified record name,
+// allowing one event type to be used across several topics
+func RecordNameStrategy(topic string, isKey bool, codec *goavro.Codec) (string, error) {
+	return recordName(codec)
+}
+
+// TopicRecordNameStrategy registers schemas under <topic>-<fully-qualified record name>,
+// allowing several event types on one topic
+func TopicRecordNameStrategy(topic string, isKey bool, codec *goavro.Codec) (string, error) {
+	name, err := recordName(codec)
+	if err != nil {
+		return "", err
+	}
+	return topic + "-" + name, nil
+}
+
+// subjectNamer is implemented by the clients mapping topics to subjects with their own SubjectNameStrategy
+type subjectNamer interface {
+	SubjectName(topic string, isKey bool, codec *goavro.Codec) (string, error)
+}
+
+// subjectName returns the subject for the key or value schema of a topic, clients not implementing
+// subjectNamer use TopicNameStrategy
+func subjectName(client SchemaRegistryClientInterface, topic string, isKey bool, codec *goavro.Codec) (string, error) {
+	if namer, ok := client.(subjectNamer); ok {
+		return namer.SubjectName(topic, isKey, codec)
+	}
+	return TopicNameStrategy(topic, isKey, codec)
+}
+
+// strategyClient maps topics to subjects with strategy for a client given in a producer config
+type strategyClient struct {
+	SchemaRegistryClientInterface
+	strategy SubjectNameStrategy
+}
+
+func (client *strategyClient) SubjectName(topic string, isKey bool, codec *goavro.Codec) (string, error) {
+	return client.strategy(topic, isKey, codec)
+}
+
+type namedSchema struct {
+	Type      interface{} `json:"type"`
+	Name      string      `json:"name"`
+	Namespace string      `json:"namespace"`
+}
+
+// recordName returns the fully-qualified name of a record schema
+func recordName(codec *goavro.Codec) (string, error) {
+	if codec == nil {
+		return "", fmt.Errorf("subject name strategy needs a record schema")
+	}
+	var schema namedSchema
+	if err := json.Unmarshal([]byte(codec.Schema()), &schema); err != nil || schema.Type != "record" {
+		return "", fmt.Errorf("subject name strategy needs a record schema, got: %s", codec.Schema())
+	}
+	if schema.Namespace == "" || strings.Contains(schema.Name, ".") {
+		return schema.Name, nil
+	}
+	return schema.Namespace + "." + schema.Name, nil
+}
@@ -0,0 +1,43 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/linkedin/goavro"
+)
+
+func TestSubjectNameStrategies(t *testing.T) {
+	codec, err := goavro.NewCodec(`{"type": "record", "name": "Created", "namespace": "com.example", "fields": [{"name": "id", "type": "string"}]}`)
+	if err != nil {
+		t.Fatalf("Could not create codec %v", err)
+	}
+	tests := []struct {
+		strategy SubjectNameStrategy
+		isKey    bool
+		expected string
+	}{
+		{TopicNameStrategy, false, "orders-value"},
+		{TopicNameStrategy, true, "orders-key"},
+		{RecordNameStrategy, false, "com.example.Created"},
+		{TopicRecordNameStrategy, false, "orders-com.example.Created"},
+	}
+	for _, test := range tests {
+		subject, err := test.strategy("orders", test.isKey, codec)
+		if err != nil {
+			t.Errorf("Error getting subject name: %v", err)
+		}
+		if subject != test.expected {
+			t.Errorf("Subjects do not match. Expected: %s, got: %s", test.expected, subject)
+		}
+	}
+}
+
+func TestRecordNameStrategy_NotARecord(t *testing.T) {
+	codec, err := goavro.NewCodec(`"string"`)
+	if err != nil {
+		t.Fatalf("Could not create codec %v", err)
+	}
+	if _, err := RecordNameStrategy("orders", true, codec); err == nil {
+		t.Errorf("Expected error for a non record schema")
+	}
+}